@@ -0,0 +1,98 @@
+package client
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/hamba/statter/v2"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// Config configures an HTTP client.
+//
+// Zero durations are replaced with sensible defaults.
+type Config struct {
+	// Name is the name of the client used in stats.
+	// If empty, the request host is used.
+	Name string
+
+	// Transport is the base transport. If nil, a transport
+	// configured with the timeouts below is used.
+	Transport http.RoundTripper
+
+	Timeout               time.Duration
+	DialTimeout           time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+	IdleConnTimeout       time.Duration
+
+	// MaxRetries is the maximum number of retries of idempotent requests.
+	// Zero disables retries.
+	MaxRetries int
+	// Backoff is the retry backoff. If nil, an exponential backoff
+	// between 100ms and 5s is used.
+	Backoff Backoff
+
+	// Stats collects client statistics, prefixed with `client`, when set.
+	Stats *statter.Statter
+
+	// TracingOpts are passed to the otelhttp transport.
+	TracingOpts []otelhttp.Option
+}
+
+// New returns an instrumented HTTP client.
+//
+// Outgoing requests carry the request ID from the request context,
+// are traced and, if configured, collect stats and are retried.
+func New(cfg Config) *http.Client {
+	rt := cfg.Transport
+	if rt == nil {
+		rt = newTransport(cfg)
+	}
+
+	rt = WithRequestID(rt)
+	if cfg.MaxRetries > 0 {
+		backoff := cfg.Backoff
+		if backoff == nil {
+			backoff = ExponentialBackoff(100*time.Millisecond, 5*time.Second)
+		}
+		rt = WithRetry(rt, cfg.MaxRetries, backoff)
+	}
+	if cfg.Stats != nil {
+		rt = WithStats(cfg.Name, cfg.Stats, rt)
+	}
+	rt = Tracing(cfg.TracingOpts...)(rt)
+
+	return &http.Client{
+		Transport: rt,
+		Timeout:   withDefault(cfg.Timeout, 30*time.Second),
+	}
+}
+
+func newTransport(cfg Config) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   withDefault(cfg.DialTimeout, 5*time.Second),
+		KeepAlive: 30 * time.Second,
+	}
+
+	return &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   10,
+		IdleConnTimeout:       withDefault(cfg.IdleConnTimeout, 90*time.Second),
+		TLSHandshakeTimeout:   withDefault(cfg.TLSHandshakeTimeout, 5*time.Second),
+		ResponseHeaderTimeout: withDefault(cfg.ResponseHeaderTimeout, 10*time.Second),
+		ExpectContinueTimeout: time.Second,
+	}
+}
+
+func withDefault[T comparable](val, def T) T {
+	var defT T
+	if val == defT {
+		return def
+	}
+	return val
+}
@@ -0,0 +1,76 @@
+package client_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hamba/pkg/v2/http/client"
+	"github.com/hamba/pkg/v2/http/request"
+	"github.com/hamba/statter/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	var (
+		calls     atomic.Int32
+		gotHeader atomic.Value
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		gotHeader.Store(req.Header.Get("X-Request-ID"))
+		if calls.Add(1) == 1 {
+			rw.Header().Set("Retry-After", "0")
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = rw.Write([]byte("ok"))
+	}))
+	t.Cleanup(srv.Close)
+
+	c := client.New(client.Config{
+		Name:       "test",
+		MaxRetries: 2,
+		Backoff:    func(int) time.Duration { return time.Millisecond },
+		Stats:      statter.New(statter.DiscardReporter, time.Second),
+	})
+
+	ctx := request.WithID(t.Context(), "my-id")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+
+	resp, err := c.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = resp.Body.Close() })
+
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "ok", string(b))
+	assert.Equal(t, int32(2), calls.Load())
+	assert.Equal(t, "my-id", gotHeader.Load())
+}
+
+func TestNew_Timeout(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+		<-req.Context().Done()
+	}))
+	t.Cleanup(srv.Close)
+
+	c := client.New(client.Config{Timeout: 10 * time.Millisecond})
+
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+
+	_, err = c.Do(req) //nolint:bodyclose // An error is expected.
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Client.Timeout exceeded")
+}
@@ -0,0 +1,2 @@
+// Package client provides HTTP client helpers.
+package client
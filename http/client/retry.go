@@ -0,0 +1,138 @@
+package client
+
+import (
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Backoff returns the duration to wait before the given retry attempt.
+//
+// Attempts start at 1 for the first retry.
+type Backoff func(attempt int) time.Duration
+
+// ExponentialBackoff returns an exponential backoff with full jitter.
+//
+// The backoff doubles with every attempt, starting at minDelay
+// and never exceeding maxDelay.
+func ExponentialBackoff(minDelay, maxDelay time.Duration) Backoff {
+	return func(attempt int) time.Duration {
+		d := maxDelay
+		if shift := attempt - 1; shift < 32 {
+			d = min(minDelay<<shift, maxDelay)
+		}
+		if d <= 0 {
+			return 0
+		}
+		return rand.N(d + 1) //nolint:gosec // Jitter does not need to be cryptographically secure.
+	}
+}
+
+// WithRetry retries idempotent requests on transport errors and on
+// `429 Too Many Requests` or `503 Service Unavailable` responses.
+//
+// The `Retry-After` response header is honored when present. Retries
+// are not attempted if the wait would exceed the request deadline.
+func WithRetry(rt http.RoundTripper, maxRetries int, backoff Backoff) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if maxRetries <= 0 || !isRetryable(req) {
+			return rt.RoundTrip(req)
+		}
+
+		ctx := req.Context()
+		for attempt := 1; ; attempt++ {
+			resp, err := rt.RoundTrip(req)
+			if attempt > maxRetries || !shouldRetry(resp, err) || ctx.Err() != nil {
+				return resp, err
+			}
+
+			wait := backoff(attempt)
+			if resp != nil {
+				if d, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+					wait = d
+				}
+			}
+			if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+				return resp, err
+			}
+
+			nextReq, rerr := rewind(req)
+			if rerr != nil {
+				return resp, err
+			}
+			if resp != nil {
+				_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+				_ = resp.Body.Close()
+			}
+			req = nextReq
+
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, ctx.Err()
+			case <-timer.C:
+			}
+		}
+	})
+}
+
+// Retry is a wrapper for WithRetry.
+func Retry(maxRetries int, backoff Backoff) func(http.RoundTripper) http.RoundTripper {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return WithRetry(next, maxRetries, backoff)
+	}
+}
+
+func isRetryable(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	_, hasIdempotencyKey := req.Header["Idempotency-Key"]
+	_, hasXIdempotencyKey := req.Header["X-Idempotency-Key"]
+	return hasIdempotencyKey || hasXIdempotencyKey
+}
+
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable
+}
+
+func rewind(req *http.Request) (*http.Request, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req, nil
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Body = body
+	return req, nil
+}
+
+func retryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
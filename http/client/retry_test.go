@@ -0,0 +1,168 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hamba/pkg/v2/http/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExponentialBackoff(t *testing.T) {
+	t.Parallel()
+
+	backoff := client.ExponentialBackoff(10*time.Millisecond, 50*time.Millisecond)
+
+	for attempt, wantMax := range map[int]time.Duration{
+		1:   10 * time.Millisecond,
+		2:   20 * time.Millisecond,
+		3:   40 * time.Millisecond,
+		4:   50 * time.Millisecond,
+		100: 50 * time.Millisecond,
+	} {
+		got := backoff(attempt)
+
+		assert.GreaterOrEqual(t, got, time.Duration(0))
+		assert.LessOrEqual(t, got, wantMax)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		method    string
+		body      string
+		header    http.Header
+		responses []int
+		errs      []error
+		wantCalls int
+		wantCode  int
+		wantErr   require.ErrorAssertionFunc
+	}{
+		{
+			name:      "retries service unavailable",
+			method:    http.MethodGet,
+			responses: []int{http.StatusServiceUnavailable, http.StatusOK},
+			wantCalls: 2,
+			wantCode:  http.StatusOK,
+			wantErr:   require.NoError,
+		},
+		{
+			name:      "retries too many requests",
+			method:    http.MethodPut,
+			body:      "test",
+			responses: []int{http.StatusTooManyRequests, http.StatusOK},
+			wantCalls: 2,
+			wantCode:  http.StatusOK,
+			wantErr:   require.NoError,
+		},
+		{
+			name:      "retries transport errors",
+			method:    http.MethodGet,
+			responses: []int{0, http.StatusOK},
+			errs:      []error{errors.New("test error"), nil},
+			wantCalls: 2,
+			wantCode:  http.StatusOK,
+			wantErr:   require.NoError,
+		},
+		{
+			name:      "stops after max retries",
+			method:    http.MethodGet,
+			responses: []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable},
+			wantCalls: 3,
+			wantCode:  http.StatusServiceUnavailable,
+			wantErr:   require.NoError,
+		},
+		{
+			name:      "does not retry other errors",
+			method:    http.MethodGet,
+			responses: []int{http.StatusInternalServerError},
+			wantCalls: 1,
+			wantCode:  http.StatusInternalServerError,
+			wantErr:   require.NoError,
+		},
+		{
+			name:      "does not retry non-idempotent requests",
+			method:    http.MethodPost,
+			responses: []int{http.StatusServiceUnavailable},
+			wantCalls: 1,
+			wantCode:  http.StatusServiceUnavailable,
+			wantErr:   require.NoError,
+		},
+		{
+			name:      "retries requests with idempotency key",
+			method:    http.MethodPost,
+			header:    http.Header{"Idempotency-Key": []string{"abc"}},
+			responses: []int{http.StatusServiceUnavailable, http.StatusOK},
+			wantCalls: 2,
+			wantCode:  http.StatusOK,
+			wantErr:   require.NoError,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			var calls int
+			rt := client.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				defer func() { calls++ }()
+
+				if req.Body != nil {
+					b := make([]byte, len(test.body))
+					_, _ = req.Body.Read(b)
+					assert.Equal(t, test.body, string(b))
+				}
+				if len(test.errs) > calls && test.errs[calls] != nil {
+					return nil, test.errs[calls]
+				}
+				return &http.Response{StatusCode: test.responses[calls], Body: http.NoBody, Header: http.Header{}}, nil
+			})
+
+			req, err := http.NewRequestWithContext(t.Context(), test.method, "http://example.com", strings.NewReader(test.body))
+			require.NoError(t, err)
+			for k, v := range test.header {
+				req.Header[k] = v
+			}
+
+			resp, err := client.Retry(2, func(int) time.Duration { return 0 })(rt).RoundTrip(req)
+
+			test.wantErr(t, err)
+			assert.Equal(t, test.wantCalls, calls)
+			assert.Equal(t, test.wantCode, resp.StatusCode)
+		})
+	}
+}
+
+func TestRetry_HonorsRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	var calls int
+	rt := client.RoundTripperFunc(func(*http.Request) (*http.Response, error) {
+		calls++
+		return &http.Response{
+			StatusCode: http.StatusServiceUnavailable,
+			Body:       http.NoBody,
+			Header:     http.Header{"Retry-After": []string{"10"}},
+		}, nil
+	})
+
+	ctx, cancel := context.WithTimeout(t.Context(), time.Second)
+	t.Cleanup(cancel)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://example.com", nil)
+	require.NoError(t, err)
+
+	resp, err := client.WithRetry(rt, 2, func(int) time.Duration { return 0 }).RoundTrip(req)
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+}
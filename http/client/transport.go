@@ -0,0 +1,156 @@
+package client
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hamba/pkg/v2/http/request"
+	"github.com/hamba/statter/v2"
+	"github.com/hamba/statter/v2/reporter/prometheus"
+	"github.com/hamba/statter/v2/tags"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// RoundTripperFunc is an adapter to allow the use of ordinary functions
+// as an http.RoundTripper.
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip calls fn(req).
+func (fn RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return fn(req)
+}
+
+// WithRequestID sets the request id from the request context on
+// outgoing requests.
+func WithRequestID(rt http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		id, ok := request.IDFrom(req.Context())
+		if !ok || id == "" || req.Header.Get("X-Request-ID") != "" {
+			return rt.RoundTrip(req)
+		}
+
+		req = req.Clone(req.Context())
+		req.Header.Set("X-Request-ID", id)
+
+		return rt.RoundTrip(req)
+	})
+}
+
+// RequestID is a wrapper for WithRequestID.
+func RequestID() func(http.RoundTripper) http.RoundTripper {
+	return WithRequestID
+}
+
+// WithTraceContext injects the trace context from the request context
+// into outgoing requests.
+//
+// If the propagator is nil, the global propagator is used.
+func WithTraceContext(rt http.RoundTripper, p propagation.TextMapPropagator) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		prop := p
+		if prop == nil {
+			prop = otel.GetTextMapPropagator()
+		}
+
+		req = req.Clone(req.Context())
+		prop.Inject(req.Context(), propagation.HeaderCarrier(req.Header))
+
+		return rt.RoundTrip(req)
+	})
+}
+
+// TraceContext is a wrapper for WithTraceContext.
+func TraceContext(p propagation.TextMapPropagator) func(http.RoundTripper) http.RoundTripper {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return WithTraceContext(next, p)
+	}
+}
+
+// WithStats collects statistics about outgoing HTTP requests.
+//
+// Metrics are prefixed with `client`, so they are kept apart from server
+// metrics on the same statter. If the name is empty, the request host is used.
+func WithStats(name string, s *statter.Statter, rt http.RoundTripper) http.RoundTripper {
+	s = s.With("client")
+
+	prometheus.RegisterHistogram(s,
+		"response.size",
+		[]string{"handler", "method", "code", "code_group"},
+		[]float64{200, 500, 900, 1500, 5000, 10000},
+		"The size of a response in bytes",
+	)
+
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		t := make([]statter.Tag, 2, 4)
+		handler := name
+		if handler == "" {
+			handler = req.URL.Host
+		}
+		t[0] = tags.Str("handler", handler)
+		t[1] = tags.Str("method", req.Method)
+
+		s.Counter("requests", t...).Inc(1)
+
+		start := time.Now()
+		resp, err := rt.RoundTrip(req)
+		dur := time.Since(start)
+		if err != nil {
+			s.Counter("errors", t...).Inc(1)
+			return nil, err
+		}
+
+		t = append(t, tags.StatusCode("code-group", resp.StatusCode))
+		t = append(t, tags.Int("code", resp.StatusCode))
+		s.Counter("responses", t...).Inc(1)
+		s.Timing("response.duration", t...).Observe(dur)
+
+		if resp.Body == nil || resp.Body == http.NoBody || resp.StatusCode == http.StatusSwitchingProtocols {
+			s.Histogram("response.size", t...).Observe(0)
+			return resp, nil
+		}
+		resp.Body = &bodyWrapper{
+			ReadCloser: resp.Body,
+			onClose: func(n int64) {
+				s.Histogram("response.size", t...).Observe(float64(n))
+			},
+		}
+		return resp, nil
+	})
+}
+
+// Stats is a wrapper for WithStats.
+func Stats(name string, s *statter.Statter) func(http.RoundTripper) http.RoundTripper {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return WithStats(name, s, next)
+	}
+}
+
+type bodyWrapper struct {
+	io.ReadCloser
+
+	bytes   int64
+	once    sync.Once
+	onClose func(int64)
+}
+
+func (b *bodyWrapper) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.bytes += int64(n)
+	return n, err
+}
+
+func (b *bodyWrapper) Close() error {
+	b.once.Do(func() { b.onClose(b.bytes) })
+	return b.ReadCloser.Close()
+}
+
+// Tracing collects traces on outgoing HTTP requests.
+func Tracing(opts ...otelhttp.Option) func(http.RoundTripper) http.RoundTripper {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return otelhttp.NewTransport(next, opts...)
+	}
+}
@@ -0,0 +1,240 @@
+package client_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hamba/pkg/v2/http/client"
+	"github.com/hamba/pkg/v2/http/request"
+	"github.com/hamba/statter/v2"
+	"github.com/hamba/statter/v2/reporter/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestRequestID(t *testing.T) {
+	t.Parallel()
+
+	var gotHeader string
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+		gotHeader = req.Header.Get("X-Request-ID")
+	}))
+	t.Cleanup(srv.Close)
+
+	c := &http.Client{Transport: client.RequestID()(http.DefaultTransport)}
+
+	ctx := request.WithID(t.Context(), "my-id")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+
+	resp, err := c.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	assert.Equal(t, "my-id", gotHeader)
+	assert.Empty(t, req.Header.Get("X-Request-ID"))
+}
+
+func TestRequestID_DoesNotOverrideHeader(t *testing.T) {
+	t.Parallel()
+
+	var gotHeader string
+	rt := client.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		gotHeader = req.Header.Get("X-Request-ID")
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	})
+
+	ctx := request.WithID(t.Context(), "my-id")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://example.com", nil)
+	require.NoError(t, err)
+	req.Header.Set("X-Request-ID", "other-id")
+
+	_, err = client.WithRequestID(rt).RoundTrip(req)
+	require.NoError(t, err)
+
+	assert.Equal(t, "other-id", gotHeader)
+}
+
+func TestTraceContext(t *testing.T) {
+	t.Parallel()
+
+	var gotHeader string
+	rt := client.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		gotHeader = req.Header.Get("Traceparent")
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	})
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x01},
+		SpanID:     trace.SpanID{0x02},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := trace.ContextWithSpanContext(t.Context(), sc)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://example.com", nil)
+	require.NoError(t, err)
+
+	_, err = client.TraceContext(propagation.TraceContext{})(rt).RoundTrip(req)
+	require.NoError(t, err)
+
+	assert.Equal(t, "00-01000000000000000000000000000000-0200000000000000-01", gotHeader)
+	assert.Empty(t, req.Header.Get("Traceparent"))
+}
+
+func TestStats(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		clientName string
+		wantTags   [][2]string
+	}{
+		{
+			name:       "with client name",
+			clientName: "my-client",
+			wantTags:   [][2]string{{"handler", "my-client"}, {"method", "GET"}},
+		},
+		{
+			name:     "without client name",
+			wantTags: [][2]string{{"handler", "example.com"}, {"method", "GET"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			var gotSize float64
+			m := &mockReporter{}
+			m.On("Counter", "client.requests", int64(1), test.wantTags)
+			wantTags := append(test.wantTags, [][2]string{{"code-group", "2xx"}, {"code", "200"}}...) //nolint:gocritic
+			m.On("Counter", "client.responses", int64(1), wantTags)
+			m.On("Histogram", "client.response.size", wantTags).Return(func(v float64) { gotSize = v })
+			m.On("Timing", "client.response.duration", wantTags).Return(func(_ time.Duration) {})
+
+			s := statter.New(m, time.Second)
+
+			rt := client.RoundTripperFunc(func(*http.Request) (*http.Response, error) {
+				rec := httptest.NewRecorder()
+				_, _ = rec.WriteString("test body")
+				return rec.Result(), nil
+			})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			t.Cleanup(cancel)
+
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://example.com/test", nil)
+			require.NoError(t, err)
+
+			resp, err := client.Stats(test.clientName, s)(rt).RoundTrip(req)
+			require.NoError(t, err)
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+
+			err = s.Close()
+			require.NoError(t, err)
+
+			m.AssertExpectations(t)
+			assert.InDelta(t, 9.0, gotSize, 0)
+		})
+	}
+}
+
+func TestStats_Error(t *testing.T) {
+	t.Parallel()
+
+	wantTags := [][2]string{{"handler", "my-client"}, {"method", "GET"}}
+	m := &mockReporter{}
+	m.On("Counter", "client.requests", int64(1), wantTags)
+	m.On("Counter", "client.errors", int64(1), wantTags)
+
+	s := statter.New(m, time.Second)
+
+	rt := client.RoundTripperFunc(func(*http.Request) (*http.Response, error) {
+		return nil, io.ErrUnexpectedEOF
+	})
+
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, "http://example.com/test", nil)
+	require.NoError(t, err)
+
+	_, err = client.WithStats("my-client", s, rt).RoundTrip(req)
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	err = s.Close()
+	require.NoError(t, err)
+
+	m.AssertExpectations(t)
+}
+
+func TestStats_Prometheus(t *testing.T) {
+	t.Parallel()
+
+	var errs []string
+	reporter := prometheus.New("test", prometheus.WithErrorLog(func(msg string) {
+		errs = append(errs, msg)
+	}))
+	s := statter.New(reporter, time.Second)
+
+	rt := client.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader("hello")),
+			Request:    req,
+		}, nil
+	})
+
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, "http://example.com/test", nil)
+	require.NoError(t, err)
+
+	resp, err := client.WithStats("my-client", s, rt).RoundTrip(req)
+	require.NoError(t, err)
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	err = s.Close()
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	reporter.Handler().ServeHTTP(rec, httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/metrics", nil))
+
+	assert.Empty(t, errs)
+	assert.Contains(t, rec.Body.String(), `test_client_response_size_sum{code="200",code_group="2xx",handler="my-client",method="GET"} 5`)
+	assert.Contains(t, rec.Body.String(), `test_client_requests{handler="my-client",method="GET"} 1`)
+}
+
+type mockReporter struct {
+	mock.Mock
+}
+
+func (r *mockReporter) Counter(name string, v int64, tags [][2]string) {
+	_ = r.Called(name, v, tags)
+}
+
+func (r *mockReporter) Gauge(name string, v float64, tags [][2]string) {
+	_ = r.Called(name, v, tags)
+}
+
+func (r *mockReporter) Histogram(name string, tags [][2]string) func(v float64) {
+	args := r.Called(name, tags)
+	fn := args.Get(0)
+	if fn == nil {
+		return nil
+	}
+	return fn.(func(float64))
+}
+
+func (r *mockReporter) Timing(name string, tags [][2]string) func(v time.Duration) {
+	args := r.Called(name, tags)
+	fn := args.Get(0)
+	if fn == nil {
+		return nil
+	}
+	return fn.(func(time.Duration))
+}
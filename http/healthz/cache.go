@@ -0,0 +1,157 @@
+package healthz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hamba/pkg/v2/wait"
+)
+
+type cachedCheck struct {
+	check HealthChecker
+	ttl   time.Duration
+
+	mu        sync.Mutex
+	err       error
+	checkedAt time.Time
+	inflight  chan struct{}
+}
+
+// Cached returns a health checker that caches the result of the check
+// for the ttl. Concurrent requests share a single check.
+//
+// The check runs detached from the cancellation of the request that
+// started it, so a request that is canceled while waiting returns its
+// cancellation cause, while the check keeps running and its result is
+// cached. A hanging check is only ever run once at a time.
+func Cached(check HealthChecker, ttl time.Duration) HealthChecker {
+	return &cachedCheck{
+		check: check,
+		ttl:   ttl,
+	}
+}
+
+func (c *cachedCheck) Name() string { return c.check.Name() }
+
+func (c *cachedCheck) Check(req *http.Request) error {
+	ctx := req.Context()
+	if ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+
+	c.mu.Lock()
+	if !c.checkedAt.IsZero() && time.Since(c.checkedAt) < c.ttl {
+		err := c.err
+		c.mu.Unlock()
+		return err
+	}
+	if c.inflight == nil {
+		c.inflight = make(chan struct{})
+		go c.refresh(req.WithContext(context.WithoutCancel(ctx)), c.inflight)
+	}
+	done := c.inflight
+	c.mu.Unlock()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.err
+}
+
+func (c *cachedCheck) refresh(req *http.Request, done chan struct{}) {
+	var err error
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("check panicked: %v", v)
+		}
+
+		c.mu.Lock()
+		c.err = err
+		c.checkedAt = time.Now()
+		c.inflight = nil
+		c.mu.Unlock()
+
+		close(done)
+	}()
+
+	err = c.check.Check(req)
+}
+
+var errNotChecked = errors.New("check has not run yet")
+
+// Poller runs a health check in the background on an interval,
+// serving the last result.
+//
+// If the last result is older than the stale duration, e.g. because the
+// check is hanging, the poller reports a failure.
+type Poller struct {
+	check    HealthChecker
+	interval time.Duration
+	stale    time.Duration
+
+	mu        sync.RWMutex
+	err       error
+	checkedAt time.Time
+}
+
+// NewPoller returns a poller for the check.
+func NewPoller(check HealthChecker, interval, stale time.Duration) *Poller {
+	return &Poller{
+		check:    check,
+		interval: interval,
+		stale:    stale,
+	}
+}
+
+// Run runs the check immediately and then on every interval, until
+// the context is done. This function is blocking.
+func (p *Poller) Run(ctx context.Context) {
+	_ = wait.PollImmediateUntil(ctx, func(ctx context.Context) (bool, error) {
+		p.poll(ctx)
+		return false, nil
+	}, p.interval)
+}
+
+func (p *Poller) poll(ctx context.Context) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "/", nil)
+	if err == nil {
+		err = p.check.Check(req)
+	}
+	if ctx.Err() != nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.err = err
+	p.checkedAt = time.Now()
+}
+
+// Name returns the name of the check.
+func (p *Poller) Name() string { return p.check.Name() }
+
+// Check returns the last result of the check.
+func (p *Poller) Check(*http.Request) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	switch {
+	case p.checkedAt.IsZero():
+		return errNotChecked
+	case time.Since(p.checkedAt) > p.stale:
+		return fmt.Errorf("last result is stale, checked %s ago", time.Since(p.checkedAt).Round(time.Millisecond))
+	default:
+		return p.err
+	}
+}
@@ -0,0 +1,178 @@
+package healthz_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hamba/pkg/v2/http/healthz"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCached(t *testing.T) {
+	var calls atomic.Int64
+	check := healthz.NamedCheck("test", func(*http.Request) error {
+		if calls.Add(1) == 1 {
+			return errors.New("test error")
+		}
+		return nil
+	})
+
+	cached := healthz.Cached(check, 50*time.Millisecond)
+
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/readyz", nil)
+
+	assert.Equal(t, "test", cached.Name())
+	require.EqualError(t, cached.Check(req), "test error")
+	require.EqualError(t, cached.Check(req), "test error")
+	assert.Equal(t, int64(1), calls.Load())
+
+	time.Sleep(60 * time.Millisecond)
+
+	require.NoError(t, cached.Check(req))
+	assert.Equal(t, int64(2), calls.Load())
+}
+
+func TestCached_DoesNotCacheCanceledRequests(t *testing.T) {
+	var calls atomic.Int64
+	check := healthz.NamedCheck("test", func(req *http.Request) error {
+		calls.Add(1)
+		return req.Context().Err()
+	})
+
+	cached := healthz.Cached(check, time.Minute)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	req := httptest.NewRequestWithContext(ctx, http.MethodGet, "/readyz", nil)
+
+	require.ErrorIs(t, cached.Check(req), context.Canceled)
+
+	req = httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/readyz", nil)
+
+	require.NoError(t, cached.Check(req))
+	require.NoError(t, cached.Check(req))
+	assert.Equal(t, int64(1), calls.Load())
+}
+
+func TestCached_HungCheck(t *testing.T) {
+	var calls atomic.Int64
+	release := make(chan struct{})
+	check := healthz.NamedCheck("hung", func(*http.Request) error {
+		calls.Add(1)
+		<-release
+		return nil
+	})
+
+	h := healthz.NewHandler("readyz", func(string) {}, []healthz.HealthChecker{
+		healthz.Cached(check, time.Minute),
+	}, healthz.CheckTimeout(10*time.Millisecond))
+
+	goroutines := runtime.NumGoroutine()
+	for range 5 {
+		req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/readyz?verbose=1", nil)
+		rec := httptest.NewRecorder()
+
+		start := time.Now()
+		h.ServeHTTP(rec, req)
+
+		assert.Less(t, time.Since(start), time.Second)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.Equal(t, "- hung failed\nreadyz check failed\n", rec.Body.String())
+	}
+	assert.Equal(t, int64(1), calls.Load())
+	// Only the single refresh goroutine should still be running.
+	for range 100 {
+		if runtime.NumGoroutine() <= goroutines+1 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), goroutines+1)
+
+	close(release)
+
+	require.Eventually(t, func() bool {
+		req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/readyz", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		return rec.Code == http.StatusOK
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, int64(1), calls.Load())
+}
+
+func TestPoller(t *testing.T) {
+	var fail atomic.Bool
+	check := healthz.NamedCheck("test", func(*http.Request) error {
+		if fail.Load() {
+			return errors.New("test error")
+		}
+		return nil
+	})
+
+	p := healthz.NewPoller(check, 10*time.Millisecond, time.Second)
+
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/readyz", nil)
+
+	assert.Equal(t, "test", p.Name())
+	require.EqualError(t, p.Check(req), "check has not run yet")
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	assert.Eventually(t, func() bool { return p.Check(req) == nil }, time.Second, 5*time.Millisecond)
+
+	fail.Store(true)
+
+	assert.Eventually(t, func() bool { return p.Check(req) != nil }, time.Second, 5*time.Millisecond)
+	require.EqualError(t, p.Check(req), "test error")
+}
+
+func TestPoller_Stale(t *testing.T) {
+	var calls atomic.Int64
+	block := make(chan struct{})
+	check := healthz.NamedCheck("test", func(*http.Request) error {
+		if calls.Add(1) > 1 {
+			<-block
+		}
+		return nil
+	})
+
+	p := healthz.NewPoller(check, 10*time.Millisecond, 50*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		close(block)
+		<-done
+	})
+
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/readyz", nil)
+
+	assert.Eventually(t, func() bool { return p.Check(req) == nil }, time.Second, 5*time.Millisecond)
+	assert.Eventually(t, func() bool {
+		err := p.Check(req)
+		return err != nil && assert.ErrorContains(t, err, "last result is stale")
+	}, time.Second, 5*time.Millisecond)
+}
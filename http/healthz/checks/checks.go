@@ -0,0 +1,127 @@
+// Package checks provides ready-made health checks.
+package checks
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"runtime"
+	"runtime/metrics"
+
+	"github.com/hamba/pkg/v2/http/healthz"
+)
+
+// Pinger is implemented by connections that can be pinged, e.g. *sql.DB.
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// Ping returns a health check that pings the connection, e.g. a *sql.DB.
+func Ping(name string, p Pinger) healthz.HealthChecker {
+	return healthz.NamedCheck(name, func(req *http.Request) error {
+		return p.PingContext(req.Context())
+	})
+}
+
+// TCPDial returns a health check that dials the TCP address.
+func TCPDial(name, addr string) healthz.HealthChecker {
+	return healthz.NamedCheck(name, func(req *http.Request) error {
+		var d net.Dialer
+		conn, err := d.DialContext(req.Context(), "tcp", addr)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	})
+}
+
+// HTTPGet returns a health check that sends a GET request to the url,
+// expecting the given response status code. If client is nil,
+// http.DefaultClient is used.
+func HTTPGet(name string, client *http.Client, url string, status int) healthz.HealthChecker {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return healthz.NamedCheck(name, func(req *http.Request) error {
+		r, err := http.NewRequestWithContext(req.Context(), http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(r)
+		if err != nil {
+			return err
+		}
+		_ = resp.Body.Close()
+
+		if resp.StatusCode != status {
+			return fmt.Errorf("unexpected status code %d, expected %d", resp.StatusCode, status)
+		}
+		return nil
+	})
+}
+
+// DNS returns a health check that resolves the host. If resolver is nil,
+// net.DefaultResolver is used.
+func DNS(name string, resolver *net.Resolver, host string) healthz.HealthChecker {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	return healthz.NamedCheck(name, func(req *http.Request) error {
+		addrs, err := resolver.LookupHost(req.Context(), host)
+		if err != nil {
+			return err
+		}
+		if len(addrs) == 0 {
+			return fmt.Errorf("no addresses found for %q", host)
+		}
+		return nil
+	})
+}
+
+// DiskFree returns a health check that fails when the free space available
+// on the file system containing path falls below minFree bytes.
+func DiskFree(name, path string, minFree uint64) healthz.HealthChecker {
+	return healthz.NamedCheck(name, func(*http.Request) error {
+		free, err := diskFree(path)
+		if err != nil {
+			return err
+		}
+		if free < minFree {
+			return fmt.Errorf("free disk space %d bytes is below %d bytes", free, minFree)
+		}
+		return nil
+	})
+}
+
+// Goroutines returns a health check that fails when the number of
+// goroutines exceeds maxGoroutines.
+func Goroutines(name string, maxGoroutines int) healthz.HealthChecker {
+	return healthz.NamedCheck(name, func(*http.Request) error {
+		if n := runtime.NumGoroutine(); n > maxGoroutines {
+			return fmt.Errorf("goroutine count %d exceeds %d", n, maxGoroutines)
+		}
+		return nil
+	})
+}
+
+const heapObjectsMetric = "/memory/classes/heap/objects:bytes"
+
+// HeapUsage returns a health check that fails when the memory occupied by
+// live and unswept heap objects exceeds maxBytes.
+func HeapUsage(name string, maxBytes uint64) healthz.HealthChecker {
+	return healthz.NamedCheck(name, func(*http.Request) error {
+		sample := []metrics.Sample{{Name: heapObjectsMetric}}
+		metrics.Read(sample)
+		if sample[0].Value.Kind() != metrics.KindUint64 {
+			return fmt.Errorf("metric %q is not supported", heapObjectsMetric)
+		}
+
+		if n := sample[0].Value.Uint64(); n > maxBytes {
+			return fmt.Errorf("heap usage %d bytes exceeds %d bytes", n, maxBytes)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,123 @@
+package checks_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"github.com/hamba/pkg/v2/http/healthz"
+	"github.com/hamba/pkg/v2/http/healthz/checks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ checks.Pinger = (*sql.DB)(nil)
+
+func TestPing(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		wantErr require.ErrorAssertionFunc
+	}{
+		{
+			name:    "ok",
+			wantErr: require.NoError,
+		},
+		{
+			name:    "failed",
+			err:     errors.New("test error"),
+			wantErr: require.Error,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			check := checks.Ping("db", pinger{err: test.err})
+
+			assert.Equal(t, "db", check.Name())
+			test.wantErr(t, runCheck(t, check))
+		})
+	}
+}
+
+func TestTCPDial(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ln.Close() })
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	closedAddr := closed.Addr().String()
+	_ = closed.Close()
+
+	require.NoError(t, runCheck(t, checks.TCPDial("tcp", ln.Addr().String())))
+	require.Error(t, runCheck(t, checks.TCPDial("tcp", closedAddr)))
+}
+
+func TestHTTPGet(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/ok" {
+			return
+		}
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(srv.Close)
+
+	require.NoError(t, runCheck(t, checks.HTTPGet("http", srv.Client(), srv.URL+"/ok", http.StatusOK)))
+
+	err := runCheck(t, checks.HTTPGet("http", nil, srv.URL+"/fail", http.StatusOK))
+	require.EqualError(t, err, "unexpected status code 503, expected 200")
+}
+
+func TestDNS(t *testing.T) {
+	failing := &net.Resolver{
+		PreferGo: true,
+		Dial: func(context.Context, string, string) (net.Conn, error) {
+			return nil, errors.New("test error")
+		},
+	}
+
+	require.NoError(t, runCheck(t, checks.DNS("dns", nil, "localhost")))
+	require.Error(t, runCheck(t, checks.DNS("dns", failing, "example.invalid")))
+}
+
+func TestDiskFree(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("disk free space is not supported on this platform")
+	}
+
+	dir := t.TempDir()
+
+	require.NoError(t, runCheck(t, checks.DiskFree("disk", dir, 0)))
+	require.ErrorContains(t, runCheck(t, checks.DiskFree("disk", dir, math.MaxUint64)), "is below")
+	require.Error(t, runCheck(t, checks.DiskFree("disk", dir+"/does-not-exist", 0)))
+}
+
+func TestGoroutines(t *testing.T) {
+	require.NoError(t, runCheck(t, checks.Goroutines("goroutines", math.MaxInt)))
+	require.ErrorContains(t, runCheck(t, checks.Goroutines("goroutines", 0)), "exceeds")
+}
+
+func TestHeapUsage(t *testing.T) {
+	require.NoError(t, runCheck(t, checks.HeapUsage("heap", math.MaxUint64)))
+	require.ErrorContains(t, runCheck(t, checks.HeapUsage("heap", 1)), "exceeds")
+}
+
+func runCheck(t *testing.T, check healthz.HealthChecker) error {
+	t.Helper()
+
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/readyz", nil)
+	return check.Check(req)
+}
+
+type pinger struct {
+	err error
+}
+
+func (p pinger) PingContext(context.Context) error { return p.err }
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package checks
+
+import "errors"
+
+func diskFree(string) (uint64, error) {
+	return 0, errors.New("disk free space is not supported on this platform")
+}
@@ -3,8 +3,18 @@ package healthz
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hamba/pkg/v2/http/render"
+	"github.com/hamba/statter/v2"
+	"github.com/hamba/statter/v2/tags"
 )
 
 // HealthChecker represents a named health checker.
@@ -39,40 +49,337 @@ func (c ping) Name() string { return "ping" }
 
 func (c ping) Check(_ *http.Request) error { return nil }
 
+// Check statuses.
+const (
+	StatusOK     = "ok"
+	StatusFailed = "failed"
+)
+
+// Result is the structured output of a health check handler.
+type Result struct {
+	Name   string        `json:"name"`
+	Status string        `json:"status"`
+	Checks []CheckResult `json:"checks"`
+}
+
+// CheckResult is the result of a single health check.
+type CheckResult struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+
+	// Duration is the duration of the check in seconds.
+	Duration float64 `json:"duration"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// HandlerOption represents a health check handler option.
+type HandlerOption func(*handlerConfig)
+
+type handlerConfig struct {
+	redact       bool
+	timeout      time.Duration
+	checkTimeout time.Duration
+	stats        *statter.Statter
+}
+
+// RedactErrors removes check errors from the JSON output. The errors
+// are still passed to the error function.
+func RedactErrors() HandlerOption {
+	return func(c *handlerConfig) {
+		c.redact = true
+	}
+}
+
+// Timeout sets the deadline for all checks to complete. Checks that have
+// not completed by the deadline are reported as failed.
+func Timeout(d time.Duration) HandlerOption {
+	return func(c *handlerConfig) {
+		c.timeout = d
+	}
+}
+
+// CheckTimeout sets the deadline for each check to complete, derived from
+// the request context. Checks that have not completed by the deadline are
+// reported as failed.
+//
+// A check is only run once at a time. Requests made while the check is
+// still running wait for its result, until their own deadline.
+func CheckTimeout(d time.Duration) HandlerOption {
+	return func(c *handlerConfig) {
+		c.checkTimeout = d
+	}
+}
+
+// Stats records the duration of each check in the `check.duration` timing,
+// tagged with the handler and check name and the check status.
+func Stats(s *statter.Statter) HandlerOption {
+	return func(c *handlerConfig) {
+		c.stats = s
+	}
+}
+
+var (
+	errTimeout      = errors.New("health check timed out")
+	errCheckTimeout = errors.New("check timed out")
+)
+
 // Handler returns an HTTP check handler.
 func Handler(name string, errFn func(string), checks ...HealthChecker) http.Handler {
-	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
-		var (
-			checkOutput     bytes.Buffer
-			failedChecks    []string
-			failedLogOutput bytes.Buffer
-		)
-		for _, check := range checks {
-			if err := check.Check(req); err != nil {
-				_, _ = fmt.Fprintf(&checkOutput, "- %s failed\n", check.Name())
-				_, _ = fmt.Fprintf(&failedLogOutput, "%s failed: %v\n", check.Name(), err)
-				failedChecks = append(failedChecks, check.Name())
-				continue
-			}
+	return NewHandler(name, errFn, checks)
+}
 
-			_, _ = fmt.Fprintf(&checkOutput, "+ %s ok\n", check.Name())
-		}
+// NewHandler returns an HTTP check handler configured with options.
+//
+// The checks are run concurrently. The checks are written as plain text,
+// unless JSON is requested either with the `Accept` header or the
+// `format=json` query parameter.
+//
+// Checks can be excluded with the `exclude` query parameter, e.g.
+// `?exclude=db&exclude=cache`, and the available checks are listed with
+// the `list` query parameter. If the handler is registered with a `{check}`
+// path wildcard, e.g. `/readyz/{check}`, only the named check is run.
+func NewHandler(name string, errFn func(string), checks []HealthChecker, opts ...HandlerOption) http.Handler {
+	var cfg handlerConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	flights := make([]*flight, len(checks))
+	for i, check := range checks {
+		flights[i] = &flight{HealthChecker: check}
+	}
 
-		if len(failedChecks) > 0 {
-			errFn(failedLogOutput.String())
-			http.Error(rw,
-				fmt.Sprintf("%s%s check failed", checkOutput.String(), name),
-				http.StatusInternalServerError,
-			)
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if _, found := req.URL.Query()["list"]; found {
+			writeList(rw, req, flights)
 			return
 		}
 
-		if _, found := req.URL.Query()["verbose"]; !found {
-			_, _ = fmt.Fprint(rw, "ok")
+		checks, ok := selectChecks(req, flights)
+		if !ok {
+			http.Error(rw, fmt.Sprintf("%s check %q not found", name, req.PathValue("check")), http.StatusNotFound)
 			return
 		}
 
-		_, _ = checkOutput.WriteTo(rw)
-		_, _ = fmt.Fprintf(rw, "%s check passed", name)
+		// The wait context is only done when a deadline is reached, so checks
+		// that complete after the request has been canceled are still reported.
+		ctx, waitCtx := req.Context(), context.WithoutCancel(req.Context())
+		if cfg.timeout > 0 {
+			var cancel, cancelWait context.CancelFunc
+			ctx, cancel = context.WithTimeoutCause(ctx, cfg.timeout, errTimeout)
+			defer cancel()
+			waitCtx, cancelWait = context.WithTimeoutCause(waitCtx, cfg.timeout, errTimeout)
+			defer cancelWait()
+		}
+
+		res, failedLogOutput := cfg.run(waitCtx, req.WithContext(ctx), name, checks)
+		if res.Status != StatusOK {
+			errFn(failedLogOutput)
+		}
+
+		if wantsJSON(req) {
+			code := http.StatusOK
+			if res.Status != StatusOK {
+				code = http.StatusInternalServerError
+			}
+			_ = render.JSON(rw, code, res)
+			return
+		}
+		writeText(rw, req, res)
 	})
 }
+
+// run runs the checks concurrently, returning the result and
+// the failed check output.
+func (c *handlerConfig) run(waitCtx context.Context, req *http.Request, name string, checks []*flight) (Result, string) {
+	res := Result{
+		Name:   name,
+		Status: StatusOK,
+		Checks: make([]CheckResult, len(checks)),
+	}
+	errs := make([]error, len(checks))
+	durs := make([]time.Duration, len(checks))
+
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Go(func() {
+			start := time.Now()
+			errs[i] = c.runCheck(waitCtx, req, check)
+			durs[i] = time.Since(start)
+		})
+	}
+	wg.Wait()
+
+	var failedLogOutput bytes.Buffer
+	for i, check := range checks {
+		res.Checks[i] = CheckResult{
+			Name:     check.Name(),
+			Status:   StatusOK,
+			Duration: durs[i].Seconds(),
+		}
+		if err := errs[i]; err != nil {
+			_, _ = fmt.Fprintf(&failedLogOutput, "%s failed: %v\n", res.Checks[i].Name, err)
+			res.Status = StatusFailed
+			res.Checks[i].Status = StatusFailed
+			if !c.redact {
+				res.Checks[i].Error = err.Error()
+			}
+		}
+
+		if c.stats != nil {
+			c.stats.Timing("check.duration",
+				tags.Str("handler", name),
+				tags.Str("check", res.Checks[i].Name),
+				tags.Str("status", res.Checks[i].Status),
+			).Observe(durs[i])
+		}
+	}
+
+	return res, failedLogOutput.String()
+}
+
+// runCheck runs the check, returning when the check completes or the wait
+// context is done. A check that does not return is left running, and is not
+// run again until it has returned.
+func (c *handlerConfig) runCheck(waitCtx context.Context, req *http.Request, check *flight) error {
+	ctx := req.Context()
+	if c.checkTimeout > 0 {
+		var cancel, cancelWait context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, c.checkTimeout, errCheckTimeout)
+		defer cancel()
+		waitCtx, cancelWait = context.WithTimeoutCause(waitCtx, c.checkTimeout, errCheckTimeout)
+		defer cancelWait()
+	}
+
+	done := check.start(req.WithContext(ctx))
+
+	select {
+	case <-done:
+		return check.result()
+	case <-waitCtx.Done():
+		return context.Cause(waitCtx)
+	}
+}
+
+// flight runs a health check, allowing a single run at a time.
+type flight struct {
+	HealthChecker
+
+	mu   sync.Mutex
+	done chan struct{}
+	err  error
+}
+
+// start runs the check if it is not already running, returning
+// a channel that is closed once the run has completed.
+func (f *flight) start(req *http.Request) <-chan struct{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.done != nil {
+		return f.done
+	}
+
+	done := make(chan struct{})
+	f.done = done
+	go func() {
+		var err error
+		defer func() {
+			if v := recover(); v != nil {
+				err = fmt.Errorf("check panicked: %v", v)
+			}
+
+			f.mu.Lock()
+			f.err = err
+			f.done = nil
+			f.mu.Unlock()
+
+			close(done)
+		}()
+
+		err = f.Check(req)
+	}()
+	return done
+}
+
+func (f *flight) result() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.err
+}
+
+// selectChecks returns the checks selected by the request path
+// without the excluded checks.
+func selectChecks[T HealthChecker](req *http.Request, checks []T) ([]T, bool) {
+	if name := req.PathValue("check"); name != "" {
+		idx := slices.IndexFunc(checks, func(check T) bool { return check.Name() == name })
+		if idx < 0 {
+			return nil, false
+		}
+		return checks[idx : idx+1], true
+	}
+
+	exclude := req.URL.Query()["exclude"]
+	if len(exclude) == 0 {
+		return checks, true
+	}
+	return slices.DeleteFunc(slices.Clone(checks), func(check T) bool {
+		return slices.Contains(exclude, check.Name())
+	}), true
+}
+
+func writeList[T HealthChecker](rw http.ResponseWriter, req *http.Request, checks []T) {
+	names := make([]string, len(checks))
+	for i, check := range checks {
+		names[i] = check.Name()
+	}
+
+	if wantsJSON(req) {
+		_ = render.JSON(rw, http.StatusOK, names)
+		return
+	}
+	for _, name := range names {
+		_, _ = fmt.Fprintln(rw, name)
+	}
+}
+
+func wantsJSON(req *http.Request) bool {
+	if req.URL.Query().Get("format") == "json" {
+		return true
+	}
+	for accept := range strings.SplitSeq(req.Header.Get("Accept"), ",") {
+		mediaType, _, _ := strings.Cut(accept, ";")
+		if strings.EqualFold(strings.TrimSpace(mediaType), render.JSONContentType) {
+			return true
+		}
+	}
+	return false
+}
+
+func writeText(rw http.ResponseWriter, req *http.Request, res Result) {
+	var checkOutput bytes.Buffer
+	for _, check := range res.Checks {
+		if check.Status != StatusOK {
+			_, _ = fmt.Fprintf(&checkOutput, "- %s failed\n", check.Name)
+			continue
+		}
+		_, _ = fmt.Fprintf(&checkOutput, "+ %s ok\n", check.Name)
+	}
+
+	if res.Status != StatusOK {
+		http.Error(rw,
+			fmt.Sprintf("%s%s check failed", checkOutput.String(), res.Name),
+			http.StatusInternalServerError,
+		)
+		return
+	}
+
+	if _, found := req.URL.Query()["verbose"]; !found {
+		_, _ = fmt.Fprint(rw, "ok")
+		return
+	}
+
+	_, _ = checkOutput.WriteTo(rw)
+	_, _ = fmt.Fprintf(rw, "%s check passed", res.Name)
+}
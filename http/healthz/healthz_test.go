@@ -1,13 +1,20 @@
 package healthz_test
 
 import (
+	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"runtime"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/hamba/pkg/v2/http/healthz"
+	"github.com/hamba/statter/v2"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 func TestHandler(t *testing.T) {
@@ -64,3 +71,344 @@ func TestHandler_WithFailingChecks(t *testing.T) {
 	assert.Equal(t, "bad failed: test error\n", gotOutput)
 	assert.Equal(t, "+ good ok\n- bad failed\nreadyz check failed\n", rec.Body.String())
 }
+
+func TestHandler_JSON(t *testing.T) {
+	goodCheck := healthz.NamedCheck("good", func(*http.Request) error { return nil })
+	badCheck := healthz.NamedCheck("bad", func(*http.Request) error { return errors.New("test error") })
+
+	tests := []struct {
+		name      string
+		url       string
+		accept    string
+		opts      []healthz.HandlerOption
+		wantError string
+	}{
+		{
+			name:      "format query",
+			url:       "/readyz?format=json",
+			wantError: "test error",
+		},
+		{
+			name:      "accept header",
+			url:       "/readyz",
+			accept:    "text/html, application/json;q=0.9",
+			wantError: "test error",
+		},
+		{
+			name: "redacted",
+			url:  "/readyz?format=json",
+			opts: []healthz.HandlerOption{healthz.RedactErrors()},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var gotOutput string
+			h := healthz.NewHandler("readyz", func(output string) {
+				gotOutput = output
+			}, []healthz.HealthChecker{goodCheck, badCheck}, test.opts...)
+
+			req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, test.url, nil)
+			if test.accept != "" {
+				req.Header.Set("Accept", test.accept)
+			}
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusInternalServerError, rec.Code)
+			assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+			assert.Equal(t, "bad failed: test error\n", gotOutput)
+
+			var got healthz.Result
+			err := json.Unmarshal(rec.Body.Bytes(), &got)
+			require.NoError(t, err)
+
+			assert.Equal(t, "readyz", got.Name)
+			assert.Equal(t, healthz.StatusFailed, got.Status)
+			require.Len(t, got.Checks, 2)
+			assert.Equal(t, "good", got.Checks[0].Name)
+			assert.Equal(t, healthz.StatusOK, got.Checks[0].Status)
+			assert.Empty(t, got.Checks[0].Error)
+			assert.Equal(t, "bad", got.Checks[1].Name)
+			assert.Equal(t, healthz.StatusFailed, got.Checks[1].Status)
+			assert.Equal(t, test.wantError, got.Checks[1].Error)
+		})
+	}
+}
+
+func TestHandler_JSONPassing(t *testing.T) {
+	goodCheck := healthz.NamedCheck("good", func(*http.Request) error { return nil })
+
+	h := healthz.Handler("livez", func(string) {}, goodCheck)
+
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/livez?format=json", nil)
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var got healthz.Result
+	err := json.Unmarshal(rec.Body.Bytes(), &got)
+	require.NoError(t, err)
+
+	assert.Equal(t, healthz.StatusOK, got.Status)
+	require.Len(t, got.Checks, 1)
+	assert.GreaterOrEqual(t, got.Checks[0].Duration, 0.0)
+}
+
+func TestHandler_RunsChecksConcurrently(t *testing.T) {
+	started := make(chan struct{})
+	first := healthz.NamedCheck("first", func(req *http.Request) error {
+		select {
+		case <-started:
+			return nil
+		case <-req.Context().Done():
+			return req.Context().Err()
+		}
+	})
+	second := healthz.NamedCheck("second", func(*http.Request) error {
+		close(started)
+		return nil
+	})
+
+	h := healthz.NewHandler("readyz", func(string) {}, []healthz.HealthChecker{first, second},
+		healthz.Timeout(time.Second),
+	)
+
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/readyz", nil)
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestHandler_Timeouts(t *testing.T) {
+	block := make(chan struct{})
+	t.Cleanup(func() { close(block) })
+
+	goodCheck := healthz.NamedCheck("good", func(*http.Request) error { return nil })
+	hungCheck := healthz.NamedCheck("hung", func(*http.Request) error {
+		<-block
+		return nil
+	})
+
+	tests := []struct {
+		name    string
+		opt     healthz.HandlerOption
+		wantErr string
+	}{
+		{
+			name:    "check timeout",
+			opt:     healthz.CheckTimeout(10 * time.Millisecond),
+			wantErr: "check timed out",
+		},
+		{
+			name:    "global timeout",
+			opt:     healthz.Timeout(10 * time.Millisecond),
+			wantErr: "health check timed out",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var gotOutput string
+			h := healthz.NewHandler("readyz", func(output string) {
+				gotOutput = output
+			}, []healthz.HealthChecker{goodCheck, hungCheck}, test.opt)
+
+			req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/readyz?format=json", nil)
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusInternalServerError, rec.Code)
+			assert.Equal(t, "hung failed: "+test.wantErr+"\n", gotOutput)
+
+			var got healthz.Result
+			err := json.Unmarshal(rec.Body.Bytes(), &got)
+			require.NoError(t, err)
+
+			require.Len(t, got.Checks, 2)
+			assert.Equal(t, healthz.StatusOK, got.Checks[0].Status)
+			assert.Equal(t, healthz.StatusFailed, got.Checks[1].Status)
+			assert.Equal(t, test.wantErr, got.Checks[1].Error)
+		})
+	}
+}
+
+func TestHandler_RunsHungCheckOnce(t *testing.T) {
+	var calls atomic.Int64
+	release := make(chan struct{})
+	hungCheck := healthz.NamedCheck("hung", func(*http.Request) error {
+		calls.Add(1)
+		<-release
+		return nil
+	})
+
+	h := healthz.NewHandler("readyz", func(string) {}, []healthz.HealthChecker{hungCheck},
+		healthz.CheckTimeout(10*time.Millisecond),
+	)
+
+	goroutines := runtime.NumGoroutine()
+	for range 5 {
+		req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/readyz?format=json", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		var got healthz.Result
+		err := json.Unmarshal(rec.Body.Bytes(), &got)
+		require.NoError(t, err)
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		require.Len(t, got.Checks, 1)
+		assert.Equal(t, "check timed out", got.Checks[0].Error)
+	}
+	assert.Equal(t, int64(1), calls.Load())
+	// Only the single check goroutine should still be running.
+	for range 100 {
+		if runtime.NumGoroutine() <= goroutines+1 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), goroutines+1)
+
+	close(release)
+
+	require.Eventually(t, func() bool {
+		req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/readyz", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		return rec.Code == http.StatusOK
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestHandler_RecoversCheckPanic(t *testing.T) {
+	panicCheck := healthz.NamedCheck("panic", func(*http.Request) error { panic("test panic") })
+
+	var gotOutput string
+	h := healthz.Handler("readyz", func(output string) {
+		gotOutput = output
+	}, panicCheck)
+
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/readyz", nil)
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, "panic failed: check panicked: test panic\n", gotOutput)
+}
+
+func TestHandler_Stats(t *testing.T) {
+	goodCheck := healthz.NamedCheck("good", func(*http.Request) error { return nil })
+	badCheck := healthz.NamedCheck("bad", func(*http.Request) error { return errors.New("test error") })
+
+	m := &mockReporter{}
+	m.On("Timing", "check.duration", [][2]string{{"handler", "readyz"}, {"check", "good"}, {"status", "ok"}}).
+		Return(func(time.Duration) {})
+	m.On("Timing", "check.duration", [][2]string{{"handler", "readyz"}, {"check", "bad"}, {"status", "failed"}}).
+		Return(func(time.Duration) {})
+	s := statter.New(m, time.Second)
+
+	h := healthz.NewHandler("readyz", func(string) {}, []healthz.HealthChecker{goodCheck, badCheck},
+		healthz.Stats(s),
+	)
+
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/readyz", nil)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	err := s.Close()
+	require.NoError(t, err)
+
+	m.AssertExpectations(t)
+}
+
+func TestHandler_SelectsChecks(t *testing.T) {
+	goodCheck := healthz.NamedCheck("good", func(*http.Request) error { return nil })
+	badCheck := healthz.NamedCheck("bad", func(*http.Request) error { return errors.New("test error") })
+
+	h := healthz.Handler("readyz", func(string) {}, goodCheck, badCheck)
+
+	mux := http.NewServeMux()
+	mux.Handle("/readyz", h)
+	mux.Handle("/readyz/{check}", h)
+
+	tests := []struct {
+		name     string
+		url      string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "exclude",
+			url:      "/readyz?verbose=1&exclude=bad",
+			wantCode: http.StatusOK,
+			wantBody: "+ good ok\nreadyz check passed",
+		},
+		{
+			name:     "sub-path",
+			url:      "/readyz/bad",
+			wantCode: http.StatusInternalServerError,
+			wantBody: "- bad failed\nreadyz check failed\n",
+		},
+		{
+			name:     "unknown sub-path",
+			url:      "/readyz/unknown",
+			wantCode: http.StatusNotFound,
+			wantBody: "readyz check \"unknown\" not found\n",
+		},
+		{
+			name:     "list",
+			url:      "/readyz?list",
+			wantCode: http.StatusOK,
+			wantBody: "good\nbad\n",
+		},
+		{
+			name:     "list json",
+			url:      "/readyz?list&format=json",
+			wantCode: http.StatusOK,
+			wantBody: `["good","bad"]`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, test.url, nil)
+			rec := httptest.NewRecorder()
+
+			mux.ServeHTTP(rec, req)
+
+			assert.Equal(t, test.wantCode, rec.Code)
+			assert.Equal(t, test.wantBody, rec.Body.String())
+		})
+	}
+}
+
+type mockReporter struct {
+	mock.Mock
+}
+
+func (r *mockReporter) Counter(name string, v int64, tags [][2]string) {
+	_ = r.Called(name, v, tags)
+}
+
+func (r *mockReporter) Gauge(name string, v float64, tags [][2]string) {
+	_ = r.Called(name, v, tags)
+}
+
+func (r *mockReporter) Histogram(name string, tags [][2]string) func(v float64) {
+	args := r.Called(name, tags)
+	return args.Get(0).(func(float64))
+}
+
+func (r *mockReporter) Timing(name string, tags [][2]string) func(v time.Duration) {
+	args := r.Called(name, tags)
+	return args.Get(0).(func(time.Duration))
+}
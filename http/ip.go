@@ -1,27 +1,210 @@
 package http
 
 import (
+	"fmt"
 	"net/http"
+	"net/netip"
 	"strings"
 )
 
+var naiveResolver = &RealIPResolver{
+	trusted: []netip.Prefix{
+		netip.MustParsePrefix("0.0.0.0/0"),
+		netip.MustParsePrefix("::/0"),
+	},
+	trustInvalid: true,
+}
+
 // RealIP resolves the real client IP address from the request.
+//
+// RealIP trusts every proxy, including requests without a valid remote
+// address, returning the left most address in the forwarding headers. As these headers can be set by any client, the
+// result should not be used for security decisions. Use a RealIPResolver
+// with a list of trusted proxies instead.
 func RealIP(r *http.Request) string {
-	hdr := r.Header
-	hdrRealIP := hdr.Get("X-Real-Ip")
-	hdrForwardedFor := hdr.Get("X-Forwarded-For")
+	if addr := naiveResolver.Resolve(r); addr.IsValid() {
+		return addr.String()
+	}
+
+	idx := strings.LastIndex(r.RemoteAddr, ":")
+	if idx == -1 {
+		return r.RemoteAddr
+	}
+	return r.RemoteAddr[:idx]
+}
+
+// RealIPResolver resolves the client IP address from a request,
+// only trusting forwarding headers set by trusted proxies.
+//
+// The forwarding headers are considered in the order `Forwarded`,
+// `X-Forwarded-For` and `X-Real-Ip`. Forwarding hops are walked from
+// right to left, skipping trusted proxies, and the first untrusted
+// address is returned as the client address.
+type RealIPResolver struct {
+	trusted []netip.Prefix
+
+	// trustInvalid trusts remote addresses that cannot be parsed.
+	trustInvalid bool
+}
+
+// NewRealIPResolver returns a resolver that trusts the given proxies.
+//
+// Each proxy may be either an IP address or a CIDR prefix.
+func NewRealIPResolver(trustedProxies ...string) (*RealIPResolver, error) {
+	trusted := make([]netip.Prefix, 0, len(trustedProxies))
+	for _, proxy := range trustedProxies {
+		if !strings.Contains(proxy, "/") {
+			addr, err := netip.ParseAddr(proxy)
+			if err != nil {
+				return nil, fmt.Errorf("parsing trusted proxy %q: %w", proxy, err)
+			}
+			addr = addr.Unmap()
+			trusted = append(trusted, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("parsing trusted proxy %q: %w", proxy, err)
+		}
+		if prefix.Addr().Is4In6() {
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), max(prefix.Bits()-96, 0))
+		}
+		trusted = append(trusted, prefix.Masked())
+	}
+
+	return &RealIPResolver{trusted: trusted}, nil
+}
 
-	if len(hdrForwardedFor) == 0 && len(hdrRealIP) == 0 {
-		idx := strings.LastIndex(r.RemoteAddr, ":")
-		if idx == -1 {
-			return r.RemoteAddr
+// Resolve returns the client address of the request.
+//
+// If the address cannot be determined, the returned address is invalid.
+func (r *RealIPResolver) Resolve(req *http.Request) netip.Addr {
+	addr := parseAddr(req.RemoteAddr)
+	if !r.isTrusted(addr) {
+		return addr
+	}
+
+	hops := forwardedHops(req.Header)
+	if hops == nil {
+		hops = forwardedForHops(req.Header)
+	}
+	if hops == nil {
+		if realIP := parseAddr(req.Header.Get("X-Real-Ip")); realIP.IsValid() {
+			return realIP
 		}
-		return r.RemoteAddr[:idx]
+		return addr
 	}
 
-	if len(hdrForwardedFor) > 0 {
-		return strings.Split(hdrForwardedFor, ",")[0]
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := parseAddr(hops[i])
+		if !hop.IsValid() {
+			// The chain is broken, the last valid hop is the best guess.
+			return addr
+		}
+
+		addr = hop
+		if !r.isTrusted(addr) {
+			return addr
+		}
+	}
+	return addr
+}
+
+func (r *RealIPResolver) isTrusted(addr netip.Addr) bool {
+	if !addr.IsValid() {
+		return r.trustInvalid
 	}
 
-	return hdrRealIP
+	for _, prefix := range r.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedHops returns the `for` parameters of the RFC 7239 Forwarded header.
+// Elements without a `for` parameter are skipped.
+func forwardedHops(hdr http.Header) []string {
+	vals := hdr.Values("Forwarded")
+	if len(vals) == 0 {
+		return nil
+	}
+
+	var hops []string
+	for _, val := range vals {
+		for _, elem := range splitQuoted(val, ',') {
+			for _, pair := range splitQuoted(elem, ';') {
+				k, v, ok := strings.Cut(pair, "=")
+				if !ok || !strings.EqualFold(strings.TrimSpace(k), "for") {
+					continue
+				}
+				hops = append(hops, strings.Trim(strings.TrimSpace(v), `"`))
+				break
+			}
+		}
+	}
+	return hops
+}
+
+func forwardedForHops(hdr http.Header) []string {
+	vals := hdr.Values("X-Forwarded-For")
+	if len(vals) == 0 {
+		return nil
+	}
+
+	var hops []string
+	for _, val := range vals {
+		hops = append(hops, strings.Split(val, ",")...)
+	}
+	return hops
+}
+
+// splitQuoted splits s by sep, ignoring separators in quoted strings.
+func splitQuoted(s string, sep byte) []string {
+	var (
+		parts  []string
+		quoted bool
+		start  int
+	)
+	for i := range len(s) {
+		switch s[i] {
+		case '"':
+			quoted = !quoted
+		case sep:
+			if quoted {
+				continue
+			}
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// parseAddr parses an IP address, optionally with a port and
+// IPv6 brackets.
+func parseAddr(s string) netip.Addr {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return netip.Addr{}
+	}
+
+	switch {
+	case s[0] == '[':
+		end := strings.IndexByte(s, ']')
+		if end == -1 {
+			return netip.Addr{}
+		}
+		s = s[1:end]
+	case strings.Count(s, ":") == 1:
+		s = s[:strings.IndexByte(s, ':')]
+	}
+
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addr.Unmap()
 }
@@ -6,6 +6,7 @@ import (
 
 	httpx "github.com/hamba/pkg/v2/http"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestRealIP(t *testing.T) {
@@ -42,6 +43,20 @@ func TestRealIP(t *testing.T) {
 			},
 			want: "1.2.3.4",
 		},
+		{
+			name: "forwarded for without remote addr",
+			req: &http.Request{
+				Header: http.Header{http.CanonicalHeaderKey("X-Forwarded-For"): []string{"1.2.3.4"}},
+			},
+			want: "1.2.3.4",
+		},
+		{
+			name: "real-ip without remote addr",
+			req: &http.Request{
+				Header: http.Header{http.CanonicalHeaderKey("X-Real-Ip"): []string{"1.2.3.4"}},
+			},
+			want: "1.2.3.4",
+		},
 		{
 			name: "forwarded for over real ip",
 			req: &http.Request{
@@ -65,3 +80,170 @@ func TestRealIP(t *testing.T) {
 		})
 	}
 }
+
+func TestNewRealIPResolver_HandlesInvalidProxies(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		proxy string
+	}{
+		{
+			name:  "invalid address",
+			proxy: "10.0.0",
+		},
+		{
+			name:  "invalid prefix",
+			proxy: "10.0.0.0/33",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := httpx.NewRealIPResolver(test.proxy)
+
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestRealIPResolver_Resolve(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		req  *http.Request
+		want string
+	}{
+		{
+			name: "untrusted remote addr ignores headers",
+			req: &http.Request{
+				RemoteAddr: "1.2.3.4:8888",
+				Header:     http.Header{"X-Forwarded-For": []string{"5.6.7.8"}},
+			},
+			want: "1.2.3.4",
+		},
+		{
+			name: "trusted remote addr without headers",
+			req:  &http.Request{RemoteAddr: "10.0.0.1:8888"},
+			want: "10.0.0.1",
+		},
+		{
+			name: "ipv6 remote addr",
+			req:  &http.Request{RemoteAddr: "[2001:db8::1]:8888"},
+			want: "2001:db8::1",
+		},
+		{
+			name: "forwarded for skips trusted hops",
+			req: &http.Request{
+				RemoteAddr: "10.0.0.1:8888",
+				Header:     http.Header{"X-Forwarded-For": []string{"9.9.9.9, 1.2.3.4, 10.0.0.2"}},
+			},
+			want: "1.2.3.4",
+		},
+		{
+			name: "forwarded for over multiple headers",
+			req: &http.Request{
+				RemoteAddr: "10.0.0.1:8888",
+				Header:     http.Header{"X-Forwarded-For": []string{"9.9.9.9", "1.2.3.4", "10.0.0.2"}},
+			},
+			want: "1.2.3.4",
+		},
+		{
+			name: "forwarded for with only trusted hops",
+			req: &http.Request{
+				RemoteAddr: "10.0.0.1:8888",
+				Header:     http.Header{"X-Forwarded-For": []string{"10.0.0.3, 10.0.0.2"}},
+			},
+			want: "10.0.0.3",
+		},
+		{
+			name: "forwarded for with invalid hop",
+			req: &http.Request{
+				RemoteAddr: "10.0.0.1:8888",
+				Header:     http.Header{"X-Forwarded-For": []string{"1.2.3.4, unknown, 10.0.0.2"}},
+			},
+			want: "10.0.0.2",
+		},
+		{
+			name: "forwarded for with ports and brackets",
+			req: &http.Request{
+				RemoteAddr: "10.0.0.1:8888",
+				Header:     http.Header{"X-Forwarded-For": []string{"[2001:db8::1]:1234, 10.0.0.2:80"}},
+			},
+			want: "2001:db8::1",
+		},
+		{
+			name: "forwarded",
+			req: &http.Request{
+				RemoteAddr: "10.0.0.1:8888",
+				Header: http.Header{
+					"Forwarded":       []string{`for=1.2.3.4;proto=https, For="[2001:db8::1]:4711";by=10.0.0.1, for=10.0.0.2`},
+					"X-Forwarded-For": []string{"5.6.7.8"},
+				},
+			},
+			want: "2001:db8::1",
+		},
+		{
+			name: "forwarded without for falls back to forwarded for",
+			req: &http.Request{
+				RemoteAddr: "10.0.0.1:8888",
+				Header: http.Header{
+					"Forwarded":       []string{`by=10.0.0.1;proto=https`},
+					"X-Forwarded-For": []string{"1.2.3.4"},
+				},
+			},
+			want: "1.2.3.4",
+		},
+		{
+			name: "forwarded with obfuscated hop",
+			req: &http.Request{
+				RemoteAddr: "10.0.0.1:8888",
+				Header:     http.Header{"Forwarded": []string{`for=1.2.3.4, for=_hidden, for=10.0.0.2`}},
+			},
+			want: "10.0.0.2",
+		},
+		{
+			name: "real ip",
+			req: &http.Request{
+				RemoteAddr: "10.0.0.1:8888",
+				Header:     http.Header{"X-Real-Ip": []string{"1.2.3.4"}},
+			},
+			want: "1.2.3.4",
+		},
+		{
+			name: "ipv4 mapped ipv6",
+			req: &http.Request{
+				RemoteAddr: "[::ffff:10.0.0.1]:8888",
+				Header:     http.Header{"X-Forwarded-For": []string{"1.2.3.4"}},
+			},
+			want: "1.2.3.4",
+		},
+	}
+
+	r, err := httpx.NewRealIPResolver("10.0.0.0/8", "2001:db8::2")
+	require.NoError(t, err)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := r.Resolve(test.req)
+
+			assert.Equal(t, test.want, got.String())
+		})
+	}
+}
+
+func TestRealIPResolver_ResolveInvalidRemoteAddr(t *testing.T) {
+	t.Parallel()
+
+	r, err := httpx.NewRealIPResolver("10.0.0.0/8")
+	require.NoError(t, err)
+
+	got := r.Resolve(&http.Request{RemoteAddr: "@"})
+
+	assert.False(t, got.IsValid())
+}
@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hamba/logger/v2"
+	lctx "github.com/hamba/logger/v2/ctx"
+	"github.com/hamba/pkg/v2/http/request"
+)
+
+// AccessLogOption represents an AccessLog middleware option.
+type AccessLogOption func(*accessLogConfig)
+
+type accessLogConfig struct {
+	sampleRate float64
+	skip       map[string]struct{}
+	combined   io.Writer
+}
+
+// AccessLogSampleRate sets the fraction of requests that are logged,
+// between 0 and 1. Server errors are always logged.
+//
+// The default sample rate is 1.
+func AccessLogSampleRate(rate float64) AccessLogOption {
+	return func(c *accessLogConfig) {
+		c.sampleRate = rate
+	}
+}
+
+// AccessLogSkipPaths sets request paths that are never logged,
+// e.g. `/livez` and `/readyz`.
+func AccessLogSkipPaths(paths ...string) AccessLogOption {
+	return func(c *accessLogConfig) {
+		for _, path := range paths {
+			c.skip[path] = struct{}{}
+		}
+	}
+}
+
+// AccessLogCombined writes access logs in the Apache combined log
+// format to w, instead of logging structured fields.
+//
+// Writes from the middleware are serialized, so w does not need to be
+// safe for concurrent use, unless it is shared with other writers.
+func AccessLogCombined(w io.Writer) AccessLogOption {
+	return func(c *accessLogConfig) {
+		c.combined = w
+	}
+}
+
+// WithAccessLog logs each request once it has been served.
+func WithAccessLog(h http.Handler, log *logger.Logger, opts ...AccessLogOption) http.Handler {
+	cfg := accessLogConfig{
+		sampleRate: 1,
+		skip:       map[string]struct{}{},
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	var mu sync.Mutex
+
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if _, ok := cfg.skip[req.URL.Path]; ok {
+			h.ServeHTTP(rw, req)
+			return
+		}
+
+		wrap := NewResponseWrapper(rw)
+
+		start := time.Now()
+		h.ServeHTTP(wrap, req)
+		dur := time.Since(start)
+
+		if wrap.Status() < http.StatusInternalServerError && cfg.sampleRate < 1 &&
+			rand.Float64() >= cfg.sampleRate { //nolint:gosec // Sampling does not need to be cryptographically secure.
+			return
+		}
+
+		if cfg.combined != nil {
+			line := combinedLogLine(req, wrap, start)
+
+			mu.Lock()
+			_, _ = io.WriteString(cfg.combined, line)
+			mu.Unlock()
+			return
+		}
+
+		fields := make([]logger.Field, 0, 8)
+		if id, ok := request.IDFrom(req.Context()); ok {
+			fields = append(fields, lctx.Str("request_id", id))
+		}
+		fields = append(fields,
+			lctx.Str("method", req.Method),
+			lctx.Str("path", req.URL.Path),
+			lctx.Int("status", wrap.Status()),
+			lctx.Int64("bytes", wrap.BytesWritten()),
+			lctx.Duration("duration", dur),
+			lctx.Str("client_ip", clientIP(req)),
+		)
+		log.Info("HTTP request", fields...)
+	})
+}
+
+// AccessLog is a wrapper for WithAccessLog.
+func AccessLog(log *logger.Logger, opts ...AccessLogOption) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return WithAccessLog(next, log, opts...)
+	}
+}
+
+// combinedLogLine formats the request in the Apache combined log format.
+func combinedLogLine(req *http.Request, wrap *ResponseWrapper, start time.Time) string {
+	user := "-"
+	if u, _, ok := req.BasicAuth(); ok && u != "" {
+		user = u
+	}
+
+	size := "-"
+	if wrap.BytesWritten() > 0 {
+		size = strconv.FormatInt(wrap.BytesWritten(), 10)
+	}
+
+	return fmt.Sprintf("%s - %s [%s] \"%s %s %s\" %d %s %q %q\n",
+		clientIP(req),
+		user,
+		start.Format("02/Jan/2006:15:04:05 -0700"),
+		req.Method,
+		req.URL.RequestURI(),
+		req.Proto,
+		wrap.Status(),
+		size,
+		headerOrDash(req.Referer()),
+		headerOrDash(req.UserAgent()),
+	)
+}
+
+func headerOrDash(v string) string {
+	if v == "" {
+		return "-"
+	}
+	return v
+}
@@ -0,0 +1,129 @@
+package middleware_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hamba/logger/v2"
+	"github.com/hamba/pkg/v2/http/middleware"
+	"github.com/hamba/pkg/v2/http/request"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccessLog(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		opts    []middleware.AccessLogOption
+		path    string
+		status  int
+		wantLog string
+	}{
+		{
+			name:    "logs request",
+			path:    "/test",
+			status:  http.StatusCreated,
+			wantLog: `^lvl=info msg="HTTP request" request_id=my-id method=GET path=/test status=201 bytes=4 duration=\S+ client_ip=192.0.2.1\n$`,
+		},
+		{
+			name:    "skips path",
+			opts:    []middleware.AccessLogOption{middleware.AccessLogSkipPaths("/livez", "/readyz")},
+			path:    "/readyz",
+			status:  http.StatusOK,
+			wantLog: `^$`,
+		},
+		{
+			name:    "samples requests",
+			opts:    []middleware.AccessLogOption{middleware.AccessLogSampleRate(0)},
+			path:    "/test",
+			status:  http.StatusOK,
+			wantLog: `^$`,
+		},
+		{
+			name:    "always logs server errors",
+			opts:    []middleware.AccessLogOption{middleware.AccessLogSampleRate(0)},
+			path:    "/test",
+			status:  http.StatusBadGateway,
+			wantLog: `status=502`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			buf := bytes.Buffer{}
+			log := logger.New(&buf, logger.LogfmtFormat(), logger.Info)
+
+			next := http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+				rw.WriteHeader(test.status)
+				_, _ = rw.Write([]byte("test"))
+			})
+
+			req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, test.path, nil)
+			req = req.WithContext(request.WithID(req.Context(), "my-id"))
+			rec := httptest.NewRecorder()
+
+			middleware.AccessLog(log, test.opts...)(next).ServeHTTP(rec, req)
+
+			assert.Equal(t, test.status, rec.Code)
+			assert.Regexp(t, regexp.MustCompile(test.wantLog), buf.String())
+		})
+	}
+}
+
+func TestAccessLog_Combined(t *testing.T) {
+	t.Parallel()
+
+	buf := bytes.Buffer{}
+	log := logger.New(&buf, logger.LogfmtFormat(), logger.Info)
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		_, _ = rw.Write([]byte("test"))
+	})
+
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/test?a=b", nil)
+	req.SetBasicAuth("bob", "secret")
+	req.Header.Set("Referer", "http://example.com/")
+	req.Header.Set("User-Agent", "test-agent")
+	rec := httptest.NewRecorder()
+
+	out := bytes.Buffer{}
+	middleware.AccessLog(log, middleware.AccessLogCombined(&out))(next).ServeHTTP(rec, req)
+
+	want := `^192\.0\.2\.1 - bob \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] "GET /test\?a=b HTTP/1\.1" 200 4 "http://example\.com/" "test-agent"\n$`
+	assert.Regexp(t, regexp.MustCompile(want), out.String())
+	assert.Empty(t, buf.String())
+}
+
+func TestAccessLog_CombinedConcurrent(t *testing.T) {
+	t.Parallel()
+
+	log := logger.New(io.Discard, logger.LogfmtFormat(), logger.Info)
+	next := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+
+	out := bytes.Buffer{}
+	h := middleware.WithAccessLog(next, log, middleware.AccessLogCombined(&out))
+
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Go(func() {
+			req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/test", nil)
+			h.ServeHTTP(httptest.NewRecorder(), req)
+		})
+	}
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	assert.Len(t, lines, 50)
+	for _, line := range lines {
+		assert.Contains(t, line, `"GET /test HTTP/1.1" 200 -`)
+	}
+}
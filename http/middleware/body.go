@@ -0,0 +1,166 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/hamba/pkg/v2/http/render"
+	"github.com/klauspost/compress/zstd"
+)
+
+// RequestBodyOption represents a RequestBody middleware option.
+type RequestBodyOption func(*requestBodyConfig)
+
+type requestBodyConfig struct {
+	maxDecodedSize int64
+}
+
+// RequestBodyMaxDecodedSize sets the maximum size of the request body
+// in bytes after it has been decoded.
+//
+// By default this is the same as the maximum body size.
+func RequestBodyMaxDecodedSize(n int64) RequestBodyOption {
+	return func(c *requestBodyConfig) {
+		c.maxDecodedSize = n
+	}
+}
+
+// WithRequestBody limits the size of request bodies to maxSize bytes and
+// transparently decodes gzip, deflate and zstd encoded request bodies.
+//
+// Requests that exceed the limit are rejected with `413 Request Entity Too Large`,
+// unless the handler has already written a response. Handlers should check
+// for http.MaxBytesError when reading the body. Requests with an unsupported
+// encoding are rejected with `415 Unsupported Media Type`.
+func WithRequestBody(h http.Handler, maxSize int64, opts ...RequestBodyOption) http.Handler {
+	cfg := requestBodyConfig{
+		maxDecodedSize: maxSize,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Body == nil || req.Body == http.NoBody {
+			h.ServeHTTP(rw, req)
+			return
+		}
+		if req.ContentLength > maxSize {
+			renderTooLarge(rw)
+			return
+		}
+
+		body := &limitedBody{ReadCloser: http.MaxBytesReader(rw, req.Body, maxSize)}
+		exceeded := func() bool { return body.exceeded }
+
+		enc := strings.ToLower(strings.TrimSpace(req.Header.Get("Content-Encoding")))
+		switch enc {
+		case "", "identity":
+			req.Body = body
+		default:
+			dec, err := newBodyDecoder(enc, body, cfg.maxDecodedSize)
+			if err != nil {
+				switch {
+				case errors.Is(err, errUnsupportedEncoding):
+					render.JSONError(rw, http.StatusUnsupportedMediaType, "unsupported content encoding")
+				case body.exceeded:
+					renderTooLarge(rw)
+				default:
+					render.JSONError(rw, http.StatusBadRequest, "invalid request body")
+				}
+				return
+			}
+
+			decBody := &limitedBody{ReadCloser: http.MaxBytesReader(rw, dec, cfg.maxDecodedSize)}
+			exceeded = func() bool { return body.exceeded || decBody.exceeded }
+			req.Body = decBody
+			req.Header.Del("Content-Encoding")
+			req.Header.Del("Content-Length")
+			req.ContentLength = -1
+		}
+
+		wrap := NewResponseWrapper(rw)
+		h.ServeHTTP(wrap, req)
+
+		if exceeded() && !wrap.WroteHeader() {
+			renderTooLarge(wrap)
+		}
+	})
+}
+
+// RequestBody is a wrapper for WithRequestBody.
+func RequestBody(maxSize int64, opts ...RequestBodyOption) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return WithRequestBody(next, maxSize, opts...)
+	}
+}
+
+func renderTooLarge(rw http.ResponseWriter) {
+	render.JSONError(rw, http.StatusRequestEntityTooLarge, "request body too large")
+}
+
+var errUnsupportedEncoding = errors.New("unsupported encoding")
+
+func newBodyDecoder(enc string, r io.ReadCloser, maxSize int64) (io.ReadCloser, error) {
+	switch enc {
+	case "gzip", "x-gzip":
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return &decoderBody{Reader: gr, closeFn: gr.Close, body: r}, nil
+	case "deflate":
+		zr, err := zlib.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return &decoderBody{Reader: zr, closeFn: zr.Close, body: r}, nil
+	case "zstd":
+		zr, err := zstd.NewReader(r,
+			zstd.WithDecoderConcurrency(1),
+			zstd.WithDecoderMaxMemory(uint64(max(maxSize, 0))),
+		)
+		if err != nil {
+			return nil, err
+		}
+		return &decoderBody{
+			Reader:  zr,
+			closeFn: func() error { zr.Close(); return nil },
+			body:    r,
+		}, nil
+	default:
+		return nil, errUnsupportedEncoding
+	}
+}
+
+// decoderBody closes both the decoder and the underlying body.
+type decoderBody struct {
+	io.Reader
+
+	closeFn func() error
+	body    io.Closer
+}
+
+func (b *decoderBody) Close() error {
+	return errors.Join(b.closeFn(), b.body.Close())
+}
+
+// limitedBody records if a body exceeded its limit.
+type limitedBody struct {
+	io.ReadCloser
+
+	exceeded bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		b.exceeded = true
+	}
+	return n, err
+}
@@ -0,0 +1,212 @@
+package middleware_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hamba/pkg/v2/http/middleware"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestBody(t *testing.T) {
+	t.Parallel()
+
+	want := `{"hello":"world"}`
+
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+	}{
+		{
+			name: "plain",
+			body: []byte(want),
+		},
+		{
+			name:     "gzip",
+			encoding: "gzip",
+			body:     encode(t, "gzip", want),
+		},
+		{
+			name:     "deflate",
+			encoding: "deflate",
+			body:     encode(t, "deflate", want),
+		},
+		{
+			name:     "zstd",
+			encoding: "zstd",
+			body:     encode(t, "zstd", want),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			var got string
+			next := http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+				b, err := io.ReadAll(req.Body)
+				require.NoError(t, err)
+
+				got = string(b)
+				assert.Empty(t, req.Header.Get("Content-Encoding"))
+			})
+
+			req := httptest.NewRequestWithContext(t.Context(), http.MethodPost, "/", bytes.NewReader(test.body))
+			req.Header.Set("Content-Encoding", test.encoding)
+			rec := httptest.NewRecorder()
+
+			middleware.RequestBody(1024)(next).ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, want, got)
+		})
+	}
+}
+
+func TestRequestBody_TooLarge(t *testing.T) {
+	t.Parallel()
+
+	body := strings.Repeat("a", 2048)
+
+	tests := []struct {
+		name          string
+		encoding      string
+		body          []byte
+		contentLength int64
+	}{
+		{
+			name:          "content length",
+			body:          []byte(body),
+			contentLength: 2048,
+		},
+		{
+			name:          "streamed",
+			body:          []byte(body),
+			contentLength: -1,
+		},
+		{
+			name:          "decoded",
+			encoding:      "gzip",
+			body:          encode(t, "gzip", body),
+			contentLength: -1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			next := http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+				_, err := io.ReadAll(req.Body)
+
+				var maxErr *http.MaxBytesError
+				assert.True(t, errors.As(err, &maxErr))
+			})
+
+			req := httptest.NewRequestWithContext(t.Context(), http.MethodPost, "/", bytes.NewReader(test.body))
+			req.ContentLength = test.contentLength
+			req.Header.Set("Content-Encoding", test.encoding)
+			rec := httptest.NewRecorder()
+
+			middleware.RequestBody(1024)(next).ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+			assert.JSONEq(t, `{"code":413,"error":"request body too large"}`, rec.Body.String())
+		})
+	}
+}
+
+func TestRequestBody_MaxDecodedSize(t *testing.T) {
+	t.Parallel()
+
+	next := http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+		_, err := io.ReadAll(req.Body)
+		assert.Error(t, err)
+	})
+
+	body := encode(t, "gzip", strings.Repeat("a", 2048))
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodPost, "/", bytes.NewReader(body))
+	req.Header.Set("Content-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+
+	middleware.RequestBody(1024, middleware.RequestBodyMaxDecodedSize(2000))(next).ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+}
+
+func TestRequestBody_InvalidEncoding(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		encoding string
+		wantCode int
+	}{
+		{
+			name:     "unsupported",
+			encoding: "br",
+			wantCode: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:     "invalid body",
+			encoding: "gzip",
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			var nextCalled bool
+			next := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+				nextCalled = true
+			})
+
+			req := httptest.NewRequestWithContext(t.Context(), http.MethodPost, "/", strings.NewReader("not encoded"))
+			req.Header.Set("Content-Encoding", test.encoding)
+			rec := httptest.NewRecorder()
+
+			middleware.RequestBody(1024)(next).ServeHTTP(rec, req)
+
+			assert.False(t, nextCalled)
+			assert.Equal(t, test.wantCode, rec.Code)
+		})
+	}
+}
+
+func encode(t *testing.T, enc, s string) []byte {
+	t.Helper()
+
+	var (
+		buf bytes.Buffer
+		w   io.WriteCloser
+		err error
+	)
+	switch enc {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "zstd":
+		w, err = zstd.NewWriter(&buf)
+		require.NoError(t, err)
+	}
+
+	_, err = w.Write([]byte(s))
+	require.NoError(t, err)
+	err = w.Close()
+	require.NoError(t, err)
+
+	return buf.Bytes()
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/hamba/logger/v2"
+	"github.com/hamba/statter/v2"
+)
+
+// Chain is an immutable list of middleware.
+//
+// The first middleware in the chain is the outermost middleware,
+// seeing the request first and the response last.
+type Chain struct {
+	mws []func(http.Handler) http.Handler
+}
+
+// NewChain returns a chain of the given middleware.
+func NewChain(mws ...func(http.Handler) http.Handler) Chain {
+	return Chain{mws: slices.Clone(mws)}
+}
+
+// Default returns the recommended chain of middleware.
+//
+// Recovery is outermost, so panics in any other middleware are handled.
+// The request ID is set before the trace, request logger and stats.
+func Default(log *logger.Logger, stats *statter.Statter) Chain {
+	return NewChain(
+		Recovery(log, RecoveryStats(stats)),
+		RequestID(),
+		Tracing("http.server"),
+		Logger(log),
+		Stats("", stats),
+	)
+}
+
+// Append returns a new chain with the middleware appended.
+func (c Chain) Append(mws ...func(http.Handler) http.Handler) Chain {
+	return Chain{mws: slices.Concat(c.mws, mws)}
+}
+
+// Extend returns a new chain with the middleware of other appended.
+func (c Chain) Extend(other Chain) Chain {
+	return c.Append(other.mws...)
+}
+
+// Then returns the handler wrapped in the chain of middleware.
+//
+// If h is nil, http.DefaultServeMux is used.
+func (c Chain) Then(h http.Handler) http.Handler {
+	if h == nil {
+		h = http.DefaultServeMux
+	}
+	for _, mw := range slices.Backward(c.mws) {
+		h = mw(h)
+	}
+	return h
+}
+
+// ThenFunc returns the handler function wrapped in the chain of middleware.
+func (c Chain) ThenFunc(fn http.HandlerFunc) http.Handler {
+	if fn == nil {
+		return c.Then(nil)
+	}
+	return c.Then(fn)
+}
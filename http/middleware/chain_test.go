@@ -0,0 +1,85 @@
+package middleware_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hamba/logger/v2"
+	"github.com/hamba/pkg/v2/http/middleware"
+	"github.com/hamba/pkg/v2/http/request"
+	"github.com/hamba/statter/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChain(t *testing.T) {
+	t.Parallel()
+
+	var order []string
+	mw := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(rw, req)
+			})
+		}
+	}
+
+	base := middleware.NewChain(mw("a"), mw("b"))
+	c := base.Append(mw("c")).Extend(middleware.NewChain(mw("d")))
+
+	h := c.ThenFunc(func(http.ResponseWriter, *http.Request) {
+		order = append(order, "handler")
+	})
+
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, []string{"a", "b", "c", "d", "handler"}, order)
+
+	order = nil
+	base.Then(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, []string{"a", "b"}, order)
+}
+
+func TestChain_ThenNil(t *testing.T) {
+	t.Parallel()
+
+	h := middleware.NewChain().Then(nil)
+
+	assert.Equal(t, http.DefaultServeMux, h)
+}
+
+func TestDefault(t *testing.T) {
+	t.Parallel()
+
+	buf := bytes.Buffer{}
+	log := logger.New(&buf, logger.LogfmtFormat(), logger.Info)
+	stats := statter.New(statter.DiscardReporter, time.Second)
+
+	h := middleware.Default(log, stats).ThenFunc(func(_ http.ResponseWriter, req *http.Request) {
+		reqLog, ok := request.LoggerFrom(req.Context())
+		require.True(t, ok)
+
+		reqLog.Info("test")
+		panic("test panic")
+	})
+
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, req)
+
+	id := rec.Header().Get("X-Request-ID")
+	assert.NotEmpty(t, id)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Contains(t, buf.String(), "msg=test request_id="+id)
+	assert.Contains(t, buf.String(), `msg="Panic while serving request"`)
+
+	_, _ = io.Copy(io.Discard, rec.Body)
+}
@@ -0,0 +1,375 @@
+package middleware
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Supported compression encodings.
+const (
+	EncodingBrotli  = "br"
+	EncodingZstd    = "zstd"
+	EncodingGzip    = "gzip"
+	EncodingDeflate = "deflate"
+)
+
+var defaultCompressContentTypes = []string{
+	"text/*",
+	"application/json",
+	"application/*+json",
+	"application/javascript",
+	"application/xml",
+	"application/*+xml",
+	"image/svg+xml",
+}
+
+// CompressOption represents a Compress middleware option.
+type CompressOption func(*compressConfig)
+
+type compressConfig struct {
+	minSize      int
+	contentTypes []string
+	encodings    []string
+}
+
+// CompressMinSize sets the minimum response size in bytes before
+// a response is compressed.
+//
+// The default minimum size is 1024 bytes.
+func CompressMinSize(n int) CompressOption {
+	return func(c *compressConfig) {
+		c.minSize = n
+	}
+}
+
+// CompressContentTypes sets the content types that are compressed.
+// A type may contain a wildcard subtype or suffix, e.g. `text/*` or
+// `application/*+json`.
+//
+// By default text, JSON, JavaScript, XML and SVG are compressed.
+func CompressContentTypes(types ...string) CompressOption {
+	return func(c *compressConfig) {
+		c.contentTypes = types
+	}
+}
+
+// CompressEncodings sets the supported encodings in order of preference.
+//
+// The default order is brotli, zstd, gzip and deflate.
+func CompressEncodings(encs ...string) CompressOption {
+	return func(c *compressConfig) {
+		c.encodings = encs
+	}
+}
+
+// WithCompress compresses responses using the encoding negotiated
+// from the `Accept-Encoding` request header.
+func WithCompress(h http.Handler, opts ...CompressOption) http.Handler {
+	cfg := compressConfig{
+		minSize:      1024,
+		contentTypes: defaultCompressContentTypes,
+		encodings:    []string{EncodingBrotli, EncodingZstd, EncodingGzip, EncodingDeflate},
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	cfg.encodings = slices.DeleteFunc(slices.Clone(cfg.encodings), func(enc string) bool {
+		_, ok := encoderPools[enc]
+		return !ok
+	})
+
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		addVary(rw.Header(), "Accept-Encoding")
+
+		enc := negotiateEncoding(req.Header.Get("Accept-Encoding"), cfg.encodings)
+		if enc == "" || req.Method == http.MethodHead {
+			h.ServeHTTP(rw, req)
+			return
+		}
+
+		cw := &compressWriter{
+			ResponseWriter: rw,
+			cfg:            &cfg,
+			encoding:       enc,
+			status:         http.StatusOK,
+		}
+		// The encoder is released on panic without committing the response,
+		// so the error can still be rendered.
+		defer cw.release()
+
+		h.ServeHTTP(cw, req)
+		cw.close()
+	})
+}
+
+// Compress is a wrapper for WithCompress.
+func Compress(opts ...CompressOption) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return WithCompress(next, opts...)
+	}
+}
+
+// negotiateEncoding returns the supported encoding with the highest
+// quality value, preferring encodings in the order given.
+func negotiateEncoding(accept string, encs []string) string {
+	if accept == "" {
+		return ""
+	}
+
+	qs := map[string]float64{}
+	for part := range strings.SplitSeq(accept, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		q := 1.0
+		for param := range strings.SplitSeq(params, ";") {
+			k, v, ok := strings.Cut(param, "=")
+			if !ok || strings.TrimSpace(k) != "q" {
+				continue
+			}
+			f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				f = 0
+			}
+			q = f
+		}
+		qs[name] = q
+	}
+
+	var (
+		best  string
+		bestQ float64
+	)
+	for _, enc := range encs {
+		q, ok := qs[enc]
+		if !ok {
+			q, ok = qs["*"]
+		}
+		if !ok || q <= bestQ {
+			continue
+		}
+		best, bestQ = enc, q
+	}
+	return best
+}
+
+func addVary(hdr http.Header, name string) {
+	for _, v := range hdr.Values("Vary") {
+		for field := range strings.SplitSeq(v, ",") {
+			field = strings.TrimSpace(field)
+			if field == "*" || strings.EqualFold(field, name) {
+				return
+			}
+		}
+	}
+	hdr.Add("Vary", name)
+}
+
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+var encoderPools = map[string]*sync.Pool{
+	EncodingBrotli: {New: func() any {
+		return brotli.NewWriterLevel(nil, brotli.DefaultCompression)
+	}},
+	EncodingZstd: {New: func() any {
+		enc, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1), zstd.WithLowerEncoderMem(true))
+		return enc
+	}},
+	EncodingGzip: {New: func() any {
+		return gzip.NewWriter(nil)
+	}},
+	EncodingDeflate: {New: func() any {
+		return zlib.NewWriter(nil)
+	}},
+}
+
+// compressWriter buffers the response until it can decide if the
+// response should be compressed.
+type compressWriter struct {
+	http.ResponseWriter
+
+	cfg      *compressConfig
+	encoding string
+
+	status  int
+	buf     []byte
+	decided bool
+	enc     encoder
+}
+
+// WriteHeader sends an HTTP response header with status code.
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.decided {
+		return
+	}
+	if code < http.StatusOK {
+		cw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	cw.status = code
+}
+
+// Write writes the data to the connection as part of an HTTP reply.
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.decided {
+		cw.buf = append(cw.buf, p...)
+		if len(cw.buf) < cw.cfg.minSize {
+			return len(p), nil
+		}
+		if err := cw.decide(true); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+
+	if cw.enc != nil {
+		return cw.enc.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// Flush sends any buffered data to the client.
+func (cw *compressWriter) Flush() {
+	_ = cw.FlushError()
+}
+
+// FlushError sends any buffered data to the client, returning any error.
+func (cw *compressWriter) FlushError() error {
+	if !cw.decided {
+		if err := cw.decide(true); err != nil {
+			return err
+		}
+	}
+	if cw.enc != nil {
+		if err := cw.enc.Flush(); err != nil {
+			return err
+		}
+	}
+	return http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+// Hijack returns a hijacked connection or an error.
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := cw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijacker not supported")
+	}
+	conn, brw, err := h.Hijack()
+	if err == nil {
+		cw.decided = true
+	}
+	return conn, brw, err
+}
+
+// Unwrap returns the underlying response writer.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// decide writes the response header, compressing the response if
+// allowed, and writes any buffered data.
+func (cw *compressWriter) decide(compress bool) error {
+	cw.decided = true
+
+	hdr := cw.Header()
+	if compress && cw.shouldCompress(hdr) {
+		hdr.Del("Content-Length")
+		hdr.Set("Content-Encoding", cw.encoding)
+
+		cw.enc = encoderPools[cw.encoding].Get().(encoder)
+		cw.enc.Reset(cw.ResponseWriter)
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	if len(cw.buf) == 0 {
+		return nil
+	}
+	var err error
+	if cw.enc != nil {
+		_, err = cw.enc.Write(cw.buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(cw.buf)
+	}
+	cw.buf = nil
+	return err
+}
+
+func (cw *compressWriter) shouldCompress(hdr http.Header) bool {
+	switch {
+	case cw.status == http.StatusNoContent, cw.status == http.StatusNotModified,
+		cw.status == http.StatusPartialContent:
+		return false
+	case hdr.Get("Content-Encoding") != "", hdr.Get("Content-Range") != "":
+		return false
+	}
+
+	ct := hdr.Get("Content-Type")
+	if ct == "" {
+		ct = http.DetectContentType(cw.buf)
+		hdr.Set("Content-Type", ct)
+	}
+	return matchContentType(ct, cw.cfg.contentTypes)
+}
+
+func (cw *compressWriter) close() {
+	if !cw.decided {
+		_ = cw.decide(len(cw.buf) >= cw.cfg.minSize)
+	}
+	if cw.enc != nil {
+		_ = cw.enc.Close()
+	}
+	cw.release()
+}
+
+func (cw *compressWriter) release() {
+	if cw.enc == nil {
+		return
+	}
+
+	cw.enc.Reset(nil)
+	encoderPools[cw.encoding].Put(cw.enc)
+	cw.enc = nil
+}
+
+func matchContentType(ct string, types []string) bool {
+	mediaType, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+
+	mainType, subType, _ := strings.Cut(mediaType, "/")
+	for _, typ := range types {
+		wantMain, wantSub, _ := strings.Cut(strings.ToLower(typ), "/")
+		if wantMain != mainType {
+			continue
+		}
+		switch {
+		case wantSub == "*", wantSub == subType:
+			return true
+		case strings.HasPrefix(wantSub, "*+") && strings.HasSuffix(subType, wantSub[1:]):
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,254 @@
+package middleware_test
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/hamba/logger/v2"
+	"github.com/hamba/pkg/v2/http/middleware"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompress(t *testing.T) {
+	t.Parallel()
+
+	body := strings.Repeat(`{"hello":"world"}`, 100)
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		wantEncoding   string
+	}{
+		{
+			name:           "prefers server order",
+			acceptEncoding: "gzip, deflate, br, zstd",
+			wantEncoding:   "br",
+		},
+		{
+			name:           "uses q values",
+			acceptEncoding: "gzip;q=1.0, br;q=0.5, zstd;q=0.8",
+			wantEncoding:   "gzip",
+		},
+		{
+			name:           "handles wildcard",
+			acceptEncoding: "br;q=0, *",
+			wantEncoding:   "zstd",
+		},
+		{
+			name:           "handles deflate",
+			acceptEncoding: "deflate",
+			wantEncoding:   "deflate",
+		},
+		{
+			name:           "handles rejected encodings",
+			acceptEncoding: "*;q=0",
+			wantEncoding:   "",
+		},
+		{
+			name:           "handles unsupported encodings",
+			acceptEncoding: "identity, compress",
+			wantEncoding:   "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			next := http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+				rw.Header().Set("Content-Type", "application/json")
+				rw.Header().Set("Content-Length", "1700")
+				rw.WriteHeader(http.StatusCreated)
+				_, _ = rw.Write([]byte(body[:500]))
+				_, _ = rw.Write([]byte(body[500:]))
+			})
+
+			req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil)
+			req.Header.Set("Accept-Encoding", test.acceptEncoding)
+			rec := httptest.NewRecorder()
+
+			middleware.Compress()(next).ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusCreated, rec.Code)
+			assert.Equal(t, "Accept-Encoding", rec.Header().Get("Vary"))
+			assert.Equal(t, test.wantEncoding, rec.Header().Get("Content-Encoding"))
+			assert.Equal(t, body, decode(t, test.wantEncoding, rec.Body))
+			if test.wantEncoding != "" {
+				assert.Empty(t, rec.Header().Get("Content-Length"))
+			}
+		})
+	}
+}
+
+func TestCompress_SkipsResponses(t *testing.T) {
+	t.Parallel()
+
+	body := strings.Repeat("a", 2000)
+
+	tests := []struct {
+		name    string
+		method  string
+		handler http.HandlerFunc
+	}{
+		{
+			name:   "small body",
+			method: http.MethodGet,
+			handler: func(rw http.ResponseWriter, _ *http.Request) {
+				rw.Header().Set("Content-Type", "text/plain")
+				_, _ = rw.Write([]byte("small"))
+			},
+		},
+		{
+			name:   "content type not allowed",
+			method: http.MethodGet,
+			handler: func(rw http.ResponseWriter, _ *http.Request) {
+				rw.Header().Set("Content-Type", "image/png")
+				_, _ = rw.Write([]byte(body))
+			},
+		},
+		{
+			name:   "already encoded",
+			method: http.MethodGet,
+			handler: func(rw http.ResponseWriter, _ *http.Request) {
+				rw.Header().Set("Content-Type", "text/plain")
+				rw.Header().Set("Content-Encoding", "custom")
+				_, _ = rw.Write([]byte(body))
+			},
+		},
+		{
+			name:   "partial content",
+			method: http.MethodGet,
+			handler: func(rw http.ResponseWriter, _ *http.Request) {
+				rw.Header().Set("Content-Type", "text/plain")
+				rw.WriteHeader(http.StatusPartialContent)
+				_, _ = rw.Write([]byte(body))
+			},
+		},
+		{
+			name:   "head request",
+			method: http.MethodHead,
+			handler: func(rw http.ResponseWriter, _ *http.Request) {
+				rw.Header().Set("Content-Type", "text/plain")
+				_, _ = rw.Write([]byte(body))
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequestWithContext(t.Context(), test.method, "/", nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+			rec := httptest.NewRecorder()
+
+			middleware.Compress()(test.handler).ServeHTTP(rec, req)
+
+			assert.Equal(t, "Accept-Encoding", rec.Header().Get("Vary"))
+			assert.NotEqual(t, "gzip", rec.Header().Get("Content-Encoding"))
+		})
+	}
+}
+
+func TestCompress_WithOptions(t *testing.T) {
+	t.Parallel()
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		_, _ = rw.Write([]byte("<html><body>small</body></html>"))
+	})
+
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip, br")
+	rec := httptest.NewRecorder()
+
+	middleware.Compress(
+		middleware.CompressMinSize(10),
+		middleware.CompressContentTypes("text/html"),
+		middleware.CompressEncodings(middleware.EncodingGzip),
+	)(next).ServeHTTP(rec, req)
+
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "<html><body>small</body></html>", decode(t, "gzip", rec.Body))
+}
+
+func TestCompress_Flush(t *testing.T) {
+	t.Parallel()
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		rw.Header().Set("Content-Type", "text/plain")
+		_, _ = rw.Write([]byte("data: 1\n\n"))
+
+		err := http.NewResponseController(rw).Flush()
+		assert.NoError(t, err)
+	})
+
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+
+	middleware.Compress()(next).ServeHTTP(rec, req)
+
+	assert.True(t, rec.Flushed)
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, "data: 1\n\n", decode(t, "gzip", rec.Body))
+}
+
+func TestCompress_WithRecovery(t *testing.T) {
+	t.Parallel()
+
+	log := logger.New(io.Discard, logger.LogfmtFormat(), logger.Info)
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		_, _ = rw.Write([]byte("partial"))
+		panic("panic text")
+	})
+
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+
+	middleware.Recovery(log)(middleware.Compress()(next)).ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Empty(t, rec.Header().Get("Content-Encoding"))
+	assert.JSONEq(t, `{"error":"internal server error","code":500}`, rec.Body.String())
+}
+
+func decode(t *testing.T, enc string, r io.Reader) string {
+	t.Helper()
+
+	var (
+		dec io.Reader
+		err error
+	)
+	switch enc {
+	case "br":
+		dec = brotli.NewReader(r)
+	case "zstd":
+		var zr *zstd.Decoder
+		zr, err = zstd.NewReader(r)
+		require.NoError(t, err)
+		defer zr.Close()
+		dec = zr
+	case "gzip":
+		dec, err = gzip.NewReader(r)
+	case "deflate":
+		dec, err = zlib.NewReader(r)
+	default:
+		dec = r
+	}
+	require.NoError(t, err)
+
+	b, err := io.ReadAll(dec)
+	require.NoError(t, err)
+	return string(b)
+}
@@ -0,0 +1,150 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/hamba/pkg/v2/http/render"
+	"github.com/hamba/statter/v2"
+	"github.com/hamba/statter/v2/tags"
+)
+
+// ConcurrencyLimitOption represents a ConcurrencyLimit middleware option.
+type ConcurrencyLimitOption func(*concurrencyLimitConfig)
+
+type concurrencyLimitConfig struct {
+	queueSize    int
+	queueTimeout time.Duration
+	retryAfter   time.Duration
+	render       func(http.ResponseWriter, *http.Request)
+	name         string
+	stats        *statter.Statter
+}
+
+// ConcurrencyLimitQueue queues up to size requests when the limit is
+// reached, waiting at most timeout for a slot to become available.
+//
+// By default requests are not queued.
+func ConcurrencyLimitQueue(size int, timeout time.Duration) ConcurrencyLimitOption {
+	return func(c *concurrencyLimitConfig) {
+		c.queueSize = size
+		c.queueTimeout = timeout
+	}
+}
+
+// ConcurrencyLimitRetryAfter sets the `Retry-After` duration sent
+// with shed requests.
+//
+// The default is 1 second.
+func ConcurrencyLimitRetryAfter(d time.Duration) ConcurrencyLimitOption {
+	return func(c *concurrencyLimitConfig) {
+		c.retryAfter = d
+	}
+}
+
+// ConcurrencyLimitRenderer sets the function used to render the response
+// of shed requests.
+//
+// The default renderer writes a JSON service unavailable error.
+func ConcurrencyLimitRenderer(fn func(http.ResponseWriter, *http.Request)) ConcurrencyLimitOption {
+	return func(c *concurrencyLimitConfig) {
+		c.render = fn
+	}
+}
+
+// ConcurrencyLimitStats collects the queue depth in the `queue.depth` gauge
+// and shed requests in the `requests.shed` counter, tagged with the
+// handler name.
+func ConcurrencyLimitStats(name string, s *statter.Statter) ConcurrencyLimitOption {
+	return func(c *concurrencyLimitConfig) {
+		c.name = name
+		c.stats = s
+	}
+}
+
+// WithConcurrencyLimit limits the number of requests being served
+// concurrently, shedding requests with `503 Service Unavailable` once the
+// limit is reached and the queue, if any, is full.
+//
+// Every middleware instance has its own limit. To limit all requests,
+// wrap the top level handler; to limit a single route, wrap its handler.
+func WithConcurrencyLimit(h http.Handler, limit int, opts ...ConcurrencyLimitOption) http.Handler {
+	cfg := concurrencyLimitConfig{
+		retryAfter: time.Second,
+		render: func(rw http.ResponseWriter, _ *http.Request) {
+			render.JSONError(rw, http.StatusServiceUnavailable, "service unavailable")
+		},
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	var (
+		sem        = make(chan struct{}, max(limit, 1))
+		queued     atomic.Int64
+		statTags   = []statter.Tag{tags.Str("handler", cfg.name)}
+		retryAfter = strconv.Itoa(max(int(cfg.retryAfter.Round(time.Second).Seconds()), 1))
+	)
+
+	setQueueDepth := func(n int64) {
+		if cfg.stats == nil {
+			return
+		}
+		cfg.stats.Gauge("queue.depth", statTags...).Set(float64(n))
+	}
+
+	acquire := func(req *http.Request) bool {
+		select {
+		case sem <- struct{}{}:
+			return true
+		default:
+		}
+
+		if cfg.queueSize <= 0 {
+			return false
+		}
+		n := queued.Add(1)
+		if n > int64(cfg.queueSize) {
+			queued.Add(-1)
+			return false
+		}
+		setQueueDepth(n)
+		defer func() { setQueueDepth(queued.Add(-1)) }()
+
+		timer := time.NewTimer(cfg.queueTimeout)
+		defer timer.Stop()
+
+		select {
+		case sem <- struct{}{}:
+			return true
+		case <-timer.C:
+			return false
+		case <-req.Context().Done():
+			return false
+		}
+	}
+
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if !acquire(req) {
+			if cfg.stats != nil {
+				cfg.stats.Counter("requests.shed", statTags...).Inc(1)
+			}
+
+			rw.Header().Set("Retry-After", retryAfter)
+			cfg.render(rw, req)
+			return
+		}
+		defer func() { <-sem }()
+
+		h.ServeHTTP(rw, req)
+	})
+}
+
+// ConcurrencyLimit is a wrapper for WithConcurrencyLimit.
+func ConcurrencyLimit(limit int, opts ...ConcurrencyLimitOption) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return WithConcurrencyLimit(next, limit, opts...)
+	}
+}
@@ -0,0 +1,123 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hamba/pkg/v2/http/middleware"
+	"github.com/hamba/statter/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConcurrencyLimit(t *testing.T) {
+	t.Parallel()
+
+	m := &mockReporter{}
+	m.On("Counter", "requests.shed", int64(1), [][2]string{{"handler", "test"}})
+	s := statter.New(m, time.Second)
+
+	startedCh := make(chan struct{})
+	releaseCh := make(chan struct{})
+	next := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		startedCh <- struct{}{}
+		<-releaseCh
+	})
+
+	h := middleware.ConcurrencyLimit(1,
+		middleware.ConcurrencyLimitRetryAfter(2*time.Second),
+		middleware.ConcurrencyLimitStats("test", s),
+	)(next)
+
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+	<-startedCh
+
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, req)
+
+	close(releaseCh)
+	wg.Wait()
+
+	err := s.Close()
+	require.NoError(t, err)
+
+	m.AssertExpectations(t)
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{"code":503,"error":"service unavailable"}`, rec.Body.String())
+}
+
+func TestConcurrencyLimit_Queue(t *testing.T) {
+	t.Parallel()
+
+	startedCh := make(chan struct{})
+	releaseCh := make(chan struct{})
+	next := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		startedCh <- struct{}{}
+		<-releaseCh
+	})
+
+	h := middleware.ConcurrencyLimit(1, middleware.ConcurrencyLimitQueue(1, 10*time.Second))(next)
+
+	var wg sync.WaitGroup
+	for range 2 {
+		wg.Go(func() {
+			req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil)
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+		})
+	}
+
+	<-startedCh
+	releaseCh <- struct{}{}
+	<-startedCh
+	releaseCh <- struct{}{}
+	wg.Wait()
+}
+
+func TestConcurrencyLimit_QueueTimeout(t *testing.T) {
+	t.Parallel()
+
+	startedCh := make(chan struct{})
+	releaseCh := make(chan struct{})
+	next := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		startedCh <- struct{}{}
+		<-releaseCh
+	})
+
+	h := middleware.ConcurrencyLimit(1, middleware.ConcurrencyLimitQueue(1, 10*time.Millisecond))(next)
+
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	})
+	<-startedCh
+
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, req)
+
+	close(releaseCh)
+	wg.Wait()
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+}
@@ -0,0 +1,219 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSOption represents a CORS middleware option.
+type CORSOption func(*corsConfig)
+
+type corsConfig struct {
+	allowAll         bool
+	origins          []string
+	wildcardOrigins  [][2]string
+	originFn         func(string) bool
+	methods          []string
+	headers          []string
+	allowAllHeaders  bool
+	exposedHeaders   []string
+	allowCredentials bool
+	maxAge           time.Duration
+}
+
+// CORSAllowedOrigins sets the allowed origins. An origin may contain a
+// wildcard subdomain, e.g. `https://*.example.com`, or be `*` to allow
+// all origins.
+//
+// By default all origins are allowed.
+func CORSAllowedOrigins(origins ...string) CORSOption {
+	return func(c *corsConfig) {
+		c.allowAll = false
+		c.origins = nil
+		c.wildcardOrigins = nil
+		for _, origin := range origins {
+			origin = strings.ToLower(origin)
+			switch {
+			case origin == "*":
+				c.allowAll = true
+			case strings.Contains(origin, "://*."):
+				prefix, suffix, _ := strings.Cut(origin, "*")
+				c.wildcardOrigins = append(c.wildcardOrigins, [2]string{prefix, suffix})
+			default:
+				c.origins = append(c.origins, origin)
+			}
+		}
+	}
+}
+
+// CORSAllowOriginFunc sets a function that is called to allow origins
+// that are not otherwise allowed.
+func CORSAllowOriginFunc(fn func(origin string) bool) CORSOption {
+	return func(c *corsConfig) {
+		c.allowAll = false
+		c.originFn = fn
+	}
+}
+
+// CORSAllowedMethods sets the allowed methods.
+//
+// By default `GET`, `HEAD` and `POST` are allowed.
+func CORSAllowedMethods(methods ...string) CORSOption {
+	return func(c *corsConfig) {
+		c.methods = make([]string, len(methods))
+		for i, method := range methods {
+			c.methods[i] = strings.ToUpper(method)
+		}
+	}
+}
+
+// CORSAllowedHeaders sets the allowed request headers, or `*` to allow
+// all headers.
+func CORSAllowedHeaders(headers ...string) CORSOption {
+	return func(c *corsConfig) {
+		c.headers = nil
+		for _, hdr := range headers {
+			if hdr == "*" {
+				c.allowAllHeaders = true
+				continue
+			}
+			c.headers = append(c.headers, http.CanonicalHeaderKey(hdr))
+		}
+	}
+}
+
+// CORSExposedHeaders sets the response headers exposed to the client.
+func CORSExposedHeaders(headers ...string) CORSOption {
+	return func(c *corsConfig) {
+		c.exposedHeaders = headers
+	}
+}
+
+// CORSAllowCredentials allows requests to include credentials.
+//
+// Credentials are only allowed for origins that are explicitly allowed,
+// either by CORSAllowedOrigins or CORSAllowOriginFunc. When all origins
+// are allowed, e.g. by default or with `*`, credentials are not allowed.
+func CORSAllowCredentials() CORSOption {
+	return func(c *corsConfig) {
+		c.allowCredentials = true
+	}
+}
+
+// CORSMaxAge sets how long the results of a preflight request may be cached.
+func CORSMaxAge(d time.Duration) CORSOption {
+	return func(c *corsConfig) {
+		c.maxAge = d
+	}
+}
+
+// WithCORS handles Cross-Origin Resource Sharing requests.
+//
+// Preflight requests are answered with `204 No Content` and are not
+// passed to the handler.
+func WithCORS(h http.Handler, opts ...CORSOption) http.Handler {
+	cfg := corsConfig{
+		allowAll: true,
+		methods:  []string{http.MethodGet, http.MethodHead, http.MethodPost},
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		origin := req.Header.Get("Origin")
+		preflight := req.Method == http.MethodOptions && req.Header.Get("Access-Control-Request-Method") != ""
+
+		hdr := rw.Header()
+		if preflight {
+			addVary(hdr, "Origin")
+			addVary(hdr, "Access-Control-Request-Method")
+			addVary(hdr, "Access-Control-Request-Headers")
+
+			if origin != "" && cfg.isOriginAllowed(origin) {
+				cfg.handlePreflight(hdr, req, origin)
+			}
+			rw.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if !cfg.allowAll {
+			addVary(hdr, "Origin")
+		}
+		if origin != "" && cfg.isOriginAllowed(origin) {
+			cfg.setAllowOrigin(hdr, origin)
+			if len(cfg.exposedHeaders) > 0 {
+				hdr.Set("Access-Control-Expose-Headers", strings.Join(cfg.exposedHeaders, ", "))
+			}
+		}
+
+		h.ServeHTTP(rw, req)
+	})
+}
+
+// CORS is a wrapper for WithCORS.
+func CORS(opts ...CORSOption) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return WithCORS(next, opts...)
+	}
+}
+
+func (c *corsConfig) handlePreflight(hdr http.Header, req *http.Request, origin string) {
+	method := strings.ToUpper(req.Header.Get("Access-Control-Request-Method"))
+	if !slices.Contains(c.methods, method) {
+		return
+	}
+
+	var reqHeaders []string
+	for v := range strings.SplitSeq(req.Header.Get("Access-Control-Request-Headers"), ",") {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		if !c.allowAllHeaders && !slices.Contains(c.headers, http.CanonicalHeaderKey(v)) {
+			return
+		}
+		reqHeaders = append(reqHeaders, v)
+	}
+
+	c.setAllowOrigin(hdr, origin)
+	hdr.Set("Access-Control-Allow-Methods", method)
+	if len(reqHeaders) > 0 {
+		hdr.Set("Access-Control-Allow-Headers", strings.Join(reqHeaders, ", "))
+	}
+	if c.maxAge > 0 {
+		hdr.Set("Access-Control-Max-Age", strconv.Itoa(int(c.maxAge.Seconds())))
+	}
+}
+
+func (c *corsConfig) setAllowOrigin(hdr http.Header, origin string) {
+	if c.allowAll {
+		// Credentials are never allowed for every origin.
+		hdr.Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+
+	hdr.Set("Access-Control-Allow-Origin", origin)
+	if c.allowCredentials {
+		hdr.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func (c *corsConfig) isOriginAllowed(origin string) bool {
+	if c.allowAll {
+		return true
+	}
+
+	lower := strings.ToLower(origin)
+	if slices.Contains(c.origins, lower) {
+		return true
+	}
+	for _, w := range c.wildcardOrigins {
+		if len(lower) > len(w[0])+len(w[1]) && strings.HasPrefix(lower, w[0]) && strings.HasSuffix(lower, w[1]) {
+			return true
+		}
+	}
+	return c.originFn != nil && c.originFn(origin)
+}
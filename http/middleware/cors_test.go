@@ -0,0 +1,229 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hamba/pkg/v2/http/middleware"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCORS(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		opts        []middleware.CORSOption
+		method      string
+		reqHeaders  map[string]string
+		wantCode    int
+		wantNext    bool
+		wantHeaders map[string]string
+	}{
+		{
+			name:       "allows all origins by default",
+			method:     http.MethodGet,
+			reqHeaders: map[string]string{"Origin": "https://example.com"},
+			wantCode:   http.StatusOK,
+			wantNext:   true,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin": "*",
+				"Vary":                        "",
+			},
+		},
+		{
+			name:       "allows exact origin",
+			opts:       []middleware.CORSOption{middleware.CORSAllowedOrigins("https://example.com")},
+			method:     http.MethodGet,
+			reqHeaders: map[string]string{"Origin": "https://Example.com"},
+			wantCode:   http.StatusOK,
+			wantNext:   true,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin": "https://Example.com",
+				"Vary":                        "Origin",
+			},
+		},
+		{
+			name:       "rejects unknown origin",
+			opts:       []middleware.CORSOption{middleware.CORSAllowedOrigins("https://example.com")},
+			method:     http.MethodGet,
+			reqHeaders: map[string]string{"Origin": "https://other.com"},
+			wantCode:   http.StatusOK,
+			wantNext:   true,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin": "",
+				"Vary":                        "Origin",
+			},
+		},
+		{
+			name:       "allows wildcard subdomain",
+			opts:       []middleware.CORSOption{middleware.CORSAllowedOrigins("https://*.example.com")},
+			method:     http.MethodGet,
+			reqHeaders: map[string]string{"Origin": "https://api.example.com"},
+			wantCode:   http.StatusOK,
+			wantNext:   true,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin": "https://api.example.com",
+			},
+		},
+		{
+			name:       "rejects wildcard without subdomain",
+			opts:       []middleware.CORSOption{middleware.CORSAllowedOrigins("https://*.example.com")},
+			method:     http.MethodGet,
+			reqHeaders: map[string]string{"Origin": "https://example.com"},
+			wantCode:   http.StatusOK,
+			wantNext:   true,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin": "",
+			},
+		},
+		{
+			name: "allows origin by func",
+			opts: []middleware.CORSOption{middleware.CORSAllowOriginFunc(func(origin string) bool {
+				return strings.HasSuffix(origin, ".test")
+			})},
+			method:     http.MethodGet,
+			reqHeaders: map[string]string{"Origin": "http://my.test"},
+			wantCode:   http.StatusOK,
+			wantNext:   true,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin": "http://my.test",
+			},
+		},
+		{
+			name: "sets credentials and exposed headers",
+			opts: []middleware.CORSOption{
+				middleware.CORSAllowedOrigins("https://example.com"),
+				middleware.CORSAllowCredentials(),
+				middleware.CORSExposedHeaders("X-Request-ID", "X-Total"),
+			},
+			method:     http.MethodGet,
+			reqHeaders: map[string]string{"Origin": "https://example.com"},
+			wantCode:   http.StatusOK,
+			wantNext:   true,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin":      "https://example.com",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Expose-Headers":    "X-Request-ID, X-Total",
+				"Vary":                             "Origin",
+			},
+		},
+		{
+			name:       "ignores credentials when all origins are allowed",
+			opts:       []middleware.CORSOption{middleware.CORSAllowCredentials()},
+			method:     http.MethodGet,
+			reqHeaders: map[string]string{"Origin": "https://evil.com"},
+			wantCode:   http.StatusOK,
+			wantNext:   true,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin":      "*",
+				"Access-Control-Allow-Credentials": "",
+			},
+		},
+		{
+			name: "ignores credentials with wildcard origin",
+			opts: []middleware.CORSOption{
+				middleware.CORSAllowedOrigins("*"),
+				middleware.CORSAllowCredentials(),
+			},
+			method:     http.MethodGet,
+			reqHeaders: map[string]string{"Origin": "https://evil.com"},
+			wantCode:   http.StatusOK,
+			wantNext:   true,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin":      "*",
+				"Access-Control-Allow-Credentials": "",
+			},
+		},
+		{
+			name: "handles preflight",
+			opts: []middleware.CORSOption{
+				middleware.CORSAllowedOrigins("https://example.com"),
+				middleware.CORSAllowedMethods("GET", "put"),
+				middleware.CORSAllowedHeaders("Content-Type", "x-custom"),
+				middleware.CORSMaxAge(10 * time.Minute),
+			},
+			method: http.MethodOptions,
+			reqHeaders: map[string]string{
+				"Origin":                         "https://example.com",
+				"Access-Control-Request-Method":  "PUT",
+				"Access-Control-Request-Headers": "content-type, X-Custom",
+			},
+			wantCode: http.StatusNoContent,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin":  "https://example.com",
+				"Access-Control-Allow-Methods": "PUT",
+				"Access-Control-Allow-Headers": "content-type, X-Custom",
+				"Access-Control-Max-Age":       "600",
+				"Vary":                         "Origin",
+			},
+		},
+		{
+			name: "rejects preflight with disallowed method",
+			opts: []middleware.CORSOption{
+				middleware.CORSAllowedMethods("GET"),
+			},
+			method: http.MethodOptions,
+			reqHeaders: map[string]string{
+				"Origin":                        "https://example.com",
+				"Access-Control-Request-Method": "DELETE",
+			},
+			wantCode: http.StatusNoContent,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin":  "",
+				"Access-Control-Allow-Methods": "",
+			},
+		},
+		{
+			name:   "rejects preflight with disallowed header",
+			method: http.MethodOptions,
+			reqHeaders: map[string]string{
+				"Origin":                         "https://example.com",
+				"Access-Control-Request-Method":  "GET",
+				"Access-Control-Request-Headers": "X-Custom",
+			},
+			wantCode: http.StatusNoContent,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin": "",
+			},
+		},
+		{
+			name:       "passes plain options requests",
+			method:     http.MethodOptions,
+			reqHeaders: map[string]string{"Origin": "https://example.com"},
+			wantCode:   http.StatusOK,
+			wantNext:   true,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin": "*",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			var nextCalled bool
+			next := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+				nextCalled = true
+			})
+
+			req := httptest.NewRequestWithContext(t.Context(), test.method, "/", nil)
+			for k, v := range test.reqHeaders {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+
+			middleware.CORS(test.opts...)(next).ServeHTTP(rec, req)
+
+			assert.Equal(t, test.wantCode, rec.Code)
+			assert.Equal(t, test.wantNext, nextCalled)
+			for k, v := range test.wantHeaders {
+				assert.Equal(t, v, rec.Header().Get(k), k)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"github.com/google/uuid"
+	"github.com/oklog/ulid/v2"
+	"github.com/segmentio/ksuid"
+)
+
+// IDGenerator generates a unique request ID.
+type IDGenerator func() string
+
+// KSUID generates a KSUID request ID.
+func KSUID() string {
+	return ksuid.New().String()
+}
+
+// UUIDv4 generates a random UUID version 4 request ID.
+func UUIDv4() string {
+	return uuid.NewString()
+}
+
+// UUIDv7 generates a time ordered UUID version 7 request ID.
+func UUIDv7() string {
+	return uuid.Must(uuid.NewV7()).String()
+}
+
+// ULID generates a time ordered ULID request ID.
+func ULID() string {
+	return ulid.Make().String()
+}
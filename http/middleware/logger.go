@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/hamba/logger/v2"
+	lctx "github.com/hamba/logger/v2/ctx"
+	httpx "github.com/hamba/pkg/v2/http"
+	"github.com/hamba/pkg/v2/http/request"
+)
+
+// WithLogger sets a request scoped logger on the request context.
+//
+// The logger is populated with the request ID, method, path and
+// client IP, and can be retrieved with request.LoggerFrom. To include
+// the request ID, this middleware must be used after WithRequestID.
+func WithLogger(h http.Handler, log *logger.Logger) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		fields := make([]logger.Field, 0, 4)
+		if id, ok := request.IDFrom(req.Context()); ok {
+			fields = append(fields, lctx.Str("request_id", id))
+		}
+		fields = append(fields,
+			lctx.Str("method", req.Method),
+			lctx.Str("path", req.URL.Path),
+			lctx.Str("client_ip", clientIP(req)),
+		)
+
+		req = req.WithContext(request.WithLogger(req.Context(), log.With(fields...)))
+
+		h.ServeHTTP(rw, req)
+	})
+}
+
+// Logger is a wrapper for WithLogger.
+func Logger(log *logger.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return WithLogger(next, log)
+	}
+}
+
+// clientIP returns the client IP set by WithRealIP, falling back
+// to httpx.RealIP.
+func clientIP(req *http.Request) string {
+	if ip, ok := request.ClientIPFrom(req.Context()); ok {
+		return ip.String()
+	}
+	return httpx.RealIP(req)
+}
@@ -0,0 +1,64 @@
+package middleware_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/hamba/logger/v2"
+	"github.com/hamba/pkg/v2/http/middleware"
+	"github.com/hamba/pkg/v2/http/request"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogger(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		ctxFn   func(*http.Request) *http.Request
+		wantLog string
+	}{
+		{
+			name:    "without request id",
+			ctxFn:   func(req *http.Request) *http.Request { return req },
+			wantLog: "lvl=info msg=test method=GET path=/some/thing client_ip=192.0.2.1\n",
+		},
+		{
+			name: "with request id and client ip",
+			ctxFn: func(req *http.Request) *http.Request {
+				ctx := request.WithID(req.Context(), "my-id")
+				ctx = request.WithClientIP(ctx, netip.MustParseAddr("1.2.3.4"))
+				return req.WithContext(ctx)
+			},
+			wantLog: "lvl=info msg=test request_id=my-id method=GET path=/some/thing client_ip=1.2.3.4\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			buf := bytes.Buffer{}
+			log := logger.New(&buf, logger.LogfmtFormat(), logger.Info)
+
+			next := http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+				reqLog, ok := request.LoggerFrom(req.Context())
+				require.True(t, ok)
+
+				reqLog.Info("test")
+			})
+
+			req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/some/thing?a=b", nil)
+			req = test.ctxFn(req)
+			rec := httptest.NewRecorder()
+
+			middleware.Logger(log)(next).ServeHTTP(rec, req)
+
+			assert.Equal(t, test.wantLog, buf.String())
+		})
+	}
+}
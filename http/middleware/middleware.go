@@ -2,27 +2,75 @@
 package middleware
 
 import (
-	"bufio"
 	"errors"
-	"net"
 	"net/http"
-	"time"
+	"strings"
 
 	"github.com/hamba/logger/v2"
 	"github.com/hamba/logger/v2/ctx"
+	"github.com/hamba/pkg/v2/http/render"
 	"github.com/hamba/pkg/v2/http/request"
 	"github.com/hamba/statter/v2"
-	"github.com/hamba/statter/v2/reporter/prometheus"
-	"github.com/hamba/statter/v2/tags"
-	"github.com/segmentio/ksuid"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
+// RecoveryOption represents a Recovery middleware option.
+type RecoveryOption func(*recoveryConfig)
+
+type recoveryConfig struct {
+	stats  *statter.Statter
+	render func(http.ResponseWriter, *http.Request)
+	hook   func(*http.Request, any)
+}
+
+// RecoveryStats counts recovered panics in the `panics` counter.
+func RecoveryStats(s *statter.Statter) RecoveryOption {
+	return func(c *recoveryConfig) {
+		c.stats = s
+	}
+}
+
+// RecoveryRenderer sets the function used to render the error response.
+//
+// The renderer is only called if the response header has not yet been
+// written. The default renderer writes a JSON internal server error.
+func RecoveryRenderer(fn func(http.ResponseWriter, *http.Request)) RecoveryOption {
+	return func(c *recoveryConfig) {
+		c.render = fn
+	}
+}
+
+// RecoveryHook sets a function that is called with every recovered panic,
+// e.g. to forward the panic to an error tracker.
+func RecoveryHook(fn func(req *http.Request, v any)) RecoveryOption {
+	return func(c *recoveryConfig) {
+		c.hook = fn
+	}
+}
+
 // WithRecovery recovers from panics and log the error.
-func WithRecovery(h http.Handler, log *logger.Logger) http.Handler {
+//
+// Panics with http.ErrAbortHandler are not recovered, allowing
+// the server to abort the response.
+func WithRecovery(h http.Handler, log *logger.Logger, opts ...RecoveryOption) http.Handler {
+	cfg := recoveryConfig{
+		render: func(rw http.ResponseWriter, _ *http.Request) {
+			render.JSONInternalServerError(rw)
+		},
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
 	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		wrap := NewResponseWrapper(rw)
+
 		defer func() {
 			if v := recover(); v != nil {
+				if err, ok := v.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+					panic(v)
+				}
+
 				log.Error("Panic while serving request",
 					ctx.Interface("err", v),
 					ctx.Str("method", req.Method),
@@ -30,136 +78,135 @@ func WithRecovery(h http.Handler, log *logger.Logger) http.Handler {
 					ctx.Stack("stack"),
 				)
 
-				rw.WriteHeader(http.StatusInternalServerError)
+				if cfg.stats != nil {
+					cfg.stats.Counter("panics").Inc(1)
+				}
+				if cfg.hook != nil {
+					cfg.hook(req, v)
+				}
+
+				if !wrap.WroteHeader() {
+					cfg.render(wrap, req)
+				}
 			}
 		}()
 
-		h.ServeHTTP(rw, req)
+		h.ServeHTTP(wrap, req)
 	})
 }
 
 // Recovery is a wrapper for WithRecovery.
-func Recovery(log *logger.Logger) func(http.Handler) http.Handler {
+func Recovery(log *logger.Logger, opts ...RecoveryOption) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
-		return WithRecovery(next, log)
+		return WithRecovery(next, log, opts...)
 	}
 }
 
-// WithRequestID sets the request id on request context and in the response.
-func WithRequestID(h http.Handler) http.Handler {
-	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
-		id := ksuid.New().String()
-
-		rw.Header().Set("X-Request-ID", id)
-		req = req.WithContext(request.WithID(req.Context(), id))
+// RequestIDOption represents a RequestID middleware option.
+type RequestIDOption func(*requestIDConfig)
 
-		h.ServeHTTP(rw, req)
-	})
+type requestIDConfig struct {
+	header    string
+	gen       IDGenerator
+	propagate bool
+	validate  func(string) bool
 }
 
-// RequestID is a wrapper for WithRequestID.
-func RequestID() func(http.Handler) http.Handler {
-	return WithRequestID
-}
-
-// WithStats collects statistics about HTTP requests.
-func WithStats(name string, s *statter.Statter, h http.Handler) http.Handler {
-	prometheus.RegisterHistogram(s,
-		"response.size",
-		[]string{"handler", "code", "code-group"},
-		[]float64{200, 500, 900, 1500, 5000, 10000},
-		"The size of a response in bytes",
-	)
-
-	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
-		t := make([]statter.Tag, 2, 4)
-		if name == "" {
-			name = req.URL.Path
-		}
-		t[0] = tags.Str("handler", name)
-		t[1] = tags.Str("method", req.Method)
-
-		s.Counter("requests", t...).Inc(1)
-
-		wrap := newResponseWrapper(rw)
-
-		start := time.Now()
-		h.ServeHTTP(wrap, req)
-		dur := time.Since(start)
-
-		t = append(t, tags.StatusCode("code-group", wrap.Status()))
-		t = append(t, tags.Int("code", wrap.Status()))
-		s.Counter("responses", t...).Inc(1)
-		s.Histogram("response.size", t...).Observe(float64(wrap.BytesWritten()))
-		s.Timing("response.duration", t...).Observe(dur)
-	})
+// RequestIDHeader sets the header used to read and write the request ID.
+//
+// The default header is `X-Request-ID`.
+func RequestIDHeader(name string) RequestIDOption {
+	return func(c *requestIDConfig) {
+		c.header = name
+	}
 }
 
-// Stats is a wrapper for WithStats.
-func Stats(name string, s *statter.Statter) func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return WithStats(name, s, next)
+// RequestIDGenerator sets the generator used to create new request IDs.
+//
+// The default generator is KSUID.
+func RequestIDGenerator(gen IDGenerator) RequestIDOption {
+	return func(c *requestIDConfig) {
+		c.gen = gen
 	}
 }
 
-// Tracing collects traces on HTTP requests.
-func Tracing(op string, opts ...otelhttp.Option) func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return otelhttp.NewHandler(next, op, opts...)
+// RequestIDPropagate accepts a request ID sent in the request header,
+// only generating a new ID if the header is missing or invalid.
+//
+// By default incoming IDs must be at most 128 characters long and
+// contain only letters, digits and the characters `-_.:+/=`.
+func RequestIDPropagate() RequestIDOption {
+	return func(c *requestIDConfig) {
+		c.propagate = true
 	}
 }
 
-type responseWrapper struct {
-	http.ResponseWriter
-
-	status int
-	bytes  int64
+// RequestIDValidator sets the function used to validate incoming request IDs.
+//
+// This implies RequestIDPropagate.
+func RequestIDValidator(fn func(string) bool) RequestIDOption {
+	return func(c *requestIDConfig) {
+		c.propagate = true
+		c.validate = fn
+	}
 }
 
-func newResponseWrapper(rw http.ResponseWriter) *responseWrapper {
-	return &responseWrapper{
-		ResponseWriter: rw,
-		status:         http.StatusOK,
+// WithRequestID sets the request id on request context and in the response.
+func WithRequestID(h http.Handler, opts ...RequestIDOption) http.Handler {
+	cfg := requestIDConfig{
+		header:   "X-Request-ID",
+		gen:      KSUID,
+		validate: validRequestID,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
 	}
-}
 
-// Write writes the data to the connection as part of an HTTP reply.
-func (rw *responseWrapper) Write(p []byte) (int, error) {
-	rw.bytes += int64(len(p))
-	return rw.ResponseWriter.Write(p)
-}
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		var id string
+		if cfg.propagate {
+			if v := req.Header.Get(cfg.header); v != "" && cfg.validate(v) {
+				id = v
+			}
+		}
+		if id == "" {
+			id = cfg.gen()
+		}
 
-// WriteHeader sends an HTTP response header with status code.
-func (rw *responseWrapper) WriteHeader(s int) {
-	rw.status = s
-	rw.ResponseWriter.WriteHeader(s)
-}
+		rw.Header().Set(cfg.header, id)
+		req = req.WithContext(request.WithID(req.Context(), id))
 
-// Status returns the status code of the response or 0 if the response has
-// not be written.
-func (rw *responseWrapper) Status() int {
-	return rw.status
+		h.ServeHTTP(rw, req)
+	})
 }
 
-// BytesWritten returns the number of bytes written to the writer.
-func (rw *responseWrapper) BytesWritten() int64 {
-	return rw.bytes
+// RequestID is a wrapper for WithRequestID.
+func RequestID(opts ...RequestIDOption) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return WithRequestID(next, opts...)
+	}
 }
 
-// Hijack returns a hijacked connection or an error.
-//
-// This is required by some websocket libraries.
-func (rw *responseWrapper) Hijack() (net.Conn, *bufio.ReadWriter, error) {
-	h, ok := rw.ResponseWriter.(http.Hijacker)
-	if !ok {
-		return nil, nil, errors.New("hijacker not supported")
+const maxRequestIDLen = 128
+
+func validRequestID(id string) bool {
+	if len(id) > maxRequestIDLen {
+		return false
 	}
-	return h.Hijack()
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case strings.ContainsRune("-_.:+/=", r):
+		default:
+			return false
+		}
+	}
+	return true
 }
 
-// Unwrap returns the underlying response writer.
-// This is used by http.ResponseController to find the first
-// response writer that implements an interface.
-func (rw *responseWrapper) Unwrap() http.ResponseWriter {
-	return rw.ResponseWriter
+// Tracing collects traces on HTTP requests.
+func Tracing(op string, opts ...otelhttp.Option) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return otelhttp.NewHandler(next, op, opts...)
+	}
 }
@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -65,10 +67,88 @@ func TestRecovery(t *testing.T) {
 			middleware.Recovery(log)(next).ServeHTTP(resp, req)
 
 			assert.Contains(t, buf.String(), test.wantLog)
+			assert.Equal(t, http.StatusInternalServerError, resp.Code)
+			assert.Equal(t, `{"code":500,"error":"internal server error"}`, resp.Body.String())
 		})
 	}
 }
 
+func TestRecovery_HeaderAlreadyWritten(t *testing.T) {
+	t.Parallel()
+
+	log := logger.New(io.Discard, logger.LogfmtFormat(), logger.Info)
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		rw.WriteHeader(http.StatusAccepted)
+		panic("panic text")
+	})
+
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+
+	middleware.Recovery(log)(next).ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Empty(t, rec.Body.String())
+}
+
+func TestRecovery_WithOptions(t *testing.T) {
+	t.Parallel()
+
+	log := logger.New(io.Discard, logger.LogfmtFormat(), logger.Info)
+
+	m := &mockReporter{}
+	m.On("Counter", "panics", int64(1), [][2]string{})
+	s := statter.New(m, time.Second)
+
+	var gotPanic any
+	hook := func(_ *http.Request, v any) {
+		gotPanic = v
+	}
+	renderer := func(rw http.ResponseWriter, _ *http.Request) {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	next := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("panic text")
+	})
+
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+
+	middleware.Recovery(log,
+		middleware.RecoveryStats(s),
+		middleware.RecoveryHook(hook),
+		middleware.RecoveryRenderer(renderer),
+	)(next).ServeHTTP(rec, req)
+
+	err := s.Close()
+	require.NoError(t, err)
+
+	m.AssertExpectations(t)
+	assert.Equal(t, "panic text", gotPanic)
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+}
+
+func TestRecovery_RepanicsAbortHandler(t *testing.T) {
+	t.Parallel()
+
+	buf := bytes.Buffer{}
+	log := logger.New(&buf, logger.LogfmtFormat(), logger.Info)
+
+	next := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic(http.ErrAbortHandler)
+	})
+
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		middleware.Recovery(log)(next).ServeHTTP(rec, req)
+	})
+	assert.Empty(t, buf.String())
+}
+
 func TestRequestID(t *testing.T) {
 	t.Parallel()
 
@@ -91,6 +171,144 @@ func TestRequestID(t *testing.T) {
 	assert.NotEmpty(t, rec.Header().Get("X-Request-ID"))
 }
 
+func TestRequestID_WithOptions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		opts       []middleware.RequestIDOption
+		header     string
+		incoming   string
+		wantHeader string
+		wantID     string
+	}{
+		{
+			name:       "ignores incoming id by default",
+			header:     "X-Request-ID",
+			incoming:   "my-id",
+			wantHeader: "X-Request-ID",
+		},
+		{
+			name:       "propagates incoming id",
+			opts:       []middleware.RequestIDOption{middleware.RequestIDPropagate()},
+			header:     "X-Request-ID",
+			incoming:   "my-id",
+			wantHeader: "X-Request-ID",
+			wantID:     "my-id",
+		},
+		{
+			name:       "rejects invalid incoming id",
+			opts:       []middleware.RequestIDOption{middleware.RequestIDPropagate()},
+			header:     "X-Request-ID",
+			incoming:   "my id\n",
+			wantHeader: "X-Request-ID",
+		},
+		{
+			name:       "rejects long incoming id",
+			opts:       []middleware.RequestIDOption{middleware.RequestIDPropagate()},
+			header:     "X-Request-ID",
+			incoming:   strings.Repeat("a", 129),
+			wantHeader: "X-Request-ID",
+		},
+		{
+			name: "uses custom validator",
+			opts: []middleware.RequestIDOption{middleware.RequestIDValidator(func(id string) bool {
+				return id == "my id"
+			})},
+			header:     "X-Request-ID",
+			incoming:   "my id",
+			wantHeader: "X-Request-ID",
+			wantID:     "my id",
+		},
+		{
+			name: "uses custom header",
+			opts: []middleware.RequestIDOption{
+				middleware.RequestIDHeader("X-Correlation-ID"),
+				middleware.RequestIDPropagate(),
+			},
+			header:     "X-Correlation-ID",
+			incoming:   "my-id",
+			wantHeader: "X-Correlation-ID",
+			wantID:     "my-id",
+		},
+		{
+			name:       "uses custom generator",
+			opts:       []middleware.RequestIDOption{middleware.RequestIDGenerator(func() string { return "gen-id" })},
+			wantHeader: "X-Request-ID",
+			wantID:     "gen-id",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			var gotID string
+			next := http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+				gotID, _ = request.IDFrom(req.Context())
+			})
+
+			req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/some/thing", nil)
+			if test.header != "" {
+				req.Header.Set(test.header, test.incoming)
+			}
+			rec := httptest.NewRecorder()
+
+			middleware.RequestID(test.opts...)(next).ServeHTTP(rec, req)
+
+			assert.NotEmpty(t, gotID)
+			assert.Equal(t, gotID, rec.Header().Get(test.wantHeader))
+			if test.wantID != "" {
+				assert.Equal(t, test.wantID, gotID)
+				return
+			}
+			assert.NotEqual(t, test.incoming, gotID)
+		})
+	}
+}
+
+func TestIDGenerators(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		gen     middleware.IDGenerator
+		pattern string
+	}{
+		{
+			name:    "ksuid",
+			gen:     middleware.KSUID,
+			pattern: `^[0-9A-Za-z]{27}$`,
+		},
+		{
+			name:    "uuid v4",
+			gen:     middleware.UUIDv4,
+			pattern: `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`,
+		},
+		{
+			name:    "uuid v7",
+			gen:     middleware.UUIDv7,
+			pattern: `^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`,
+		},
+		{
+			name:    "ulid",
+			gen:     middleware.ULID,
+			pattern: `^[0-9A-HJKMNP-TV-Z]{26}$`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := test.gen()
+
+			assert.Regexp(t, test.pattern, got)
+			assert.NotEqual(t, got, test.gen())
+		})
+	}
+}
+
 func TestStats(t *testing.T) {
 	t.Parallel()
 
@@ -98,18 +316,21 @@ func TestStats(t *testing.T) {
 		name        string
 		handlerName string
 		path        string
+		wantEntry   string
 		wantTags    [][2]string
 	}{
 		{
 			name:        "with handler name",
 			handlerName: "my-handler",
 			path:        "/test",
+			wantEntry:   "my-handler",
 			wantTags:    [][2]string{{"handler", "my-handler"}, {"method", "GET"}},
 		},
 		{
-			name:     "without handler name",
-			path:     "/test",
-			wantTags: [][2]string{{"handler", "/test"}, {"method", "GET"}},
+			name:      "without handler name",
+			path:      "/test",
+			wantEntry: "all",
+			wantTags:  [][2]string{{"handler", "/test"}, {"method", "GET"}},
 		},
 	}
 
@@ -118,11 +339,14 @@ func TestStats(t *testing.T) {
 			t.Parallel()
 
 			m := &mockReporter{}
-			m.On("Counter", "requests", int64(1), test.wantTags)
+			m.On("Counter", "requests", int64(1), [][2]string{{"handler", test.wantEntry}, {"method", "GET"}})
+			m.On("Gauge", "inflight", float64(0), [][2]string{{"handler", test.wantEntry}})
 			wantTags := append(test.wantTags, [][2]string{{"code-group", "3xx"}, {"code", "305"}}...) //nolint:gocritic
 			m.On("Counter", "responses", int64(1), wantTags)
+			m.On("Histogram", "request.size", wantTags).Return(func(_ float64) {})
 			m.On("Histogram", "response.size", wantTags).Return(func(_ float64) {})
 			m.On("Timing", "response.duration", wantTags).Return(func(_ time.Duration) {})
+			m.On("Timing", "response.ttfb", wantTags).Return(func(_ time.Duration) {})
 
 			s := statter.New(m, time.Second)
 
@@ -0,0 +1,192 @@
+package middleware
+
+import (
+	"container/list"
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hamba/pkg/v2/http/render"
+	"github.com/hamba/pkg/v2/http/request"
+)
+
+// RateLimitResult is the result of taking a token from a rate limit store.
+type RateLimitResult struct {
+	// Allowed is true if the request is within the rate limit.
+	Allowed bool
+
+	// Limit is the maximum number of requests in the rate limit period.
+	Limit int
+
+	// Remaining is the number of requests remaining.
+	Remaining int
+
+	// Reset is the time until the quota is fully restored.
+	Reset time.Duration
+
+	// RetryAfter is the time until the next request is allowed.
+	// It is zero when the request is allowed.
+	RetryAfter time.Duration
+}
+
+// RateLimitStore represents a rate limit backend.
+type RateLimitStore interface {
+	// Take takes a token for the given key.
+	Take(ctx context.Context, key string) (RateLimitResult, error)
+}
+
+// RateLimitOption represents a RateLimit middleware option.
+type RateLimitOption func(*rateLimitConfig)
+
+type rateLimitConfig struct {
+	keyFn func(*http.Request) string
+}
+
+// RateLimitKey sets the function used to determine the rate limit key
+// of a request.
+//
+// The default key is the client IP set by the RealIP middleware, falling
+// back to the remote address of the request. Forwarding headers are not
+// trusted unless they have been resolved by RealIP.
+func RateLimitKey(fn func(*http.Request) string) RateLimitOption {
+	return func(c *rateLimitConfig) {
+		c.keyFn = fn
+	}
+}
+
+// WithRateLimit limits the rate of requests per key, responding with
+// `429 Too Many Requests` once the limit is exceeded.
+//
+// The `RateLimit-Limit`, `RateLimit-Remaining` and `RateLimit-Reset`
+// headers are set on every response. If the store returns an error,
+// the request is allowed.
+func WithRateLimit(h http.Handler, store RateLimitStore, opts ...RateLimitOption) http.Handler {
+	cfg := rateLimitConfig{
+		keyFn: rateLimitKey,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		res, err := store.Take(req.Context(), cfg.keyFn(req))
+		if err != nil {
+			h.ServeHTTP(rw, req)
+			return
+		}
+
+		hdr := rw.Header()
+		hdr.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+		hdr.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		hdr.Set("RateLimit-Reset", ceilSeconds(res.Reset))
+
+		if !res.Allowed {
+			hdr.Set("Retry-After", ceilSeconds(res.RetryAfter))
+			render.JSONError(rw, http.StatusTooManyRequests, "too many requests")
+			return
+		}
+
+		h.ServeHTTP(rw, req)
+	})
+}
+
+// RateLimit is a wrapper for WithRateLimit.
+func RateLimit(store RateLimitStore, opts ...RateLimitOption) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return WithRateLimit(next, store, opts...)
+	}
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
+
+// MemoryRateLimitStore is an in-memory token bucket rate limit store.
+//
+// The least recently used keys are evicted once the maximum
+// number of keys is reached.
+type MemoryRateLimitStore struct {
+	limit   int
+	rate    float64
+	maxKeys int
+
+	mu      sync.Mutex
+	buckets map[string]*list.Element
+	lru     *list.List
+}
+
+type bucket struct {
+	key    string
+	tokens float64
+	last   time.Time
+}
+
+// NewMemoryRateLimitStore returns an in-memory store allowing limit
+// requests per period for each key, keeping at most maxKeys keys.
+func NewMemoryRateLimitStore(limit int, period time.Duration, maxKeys int) *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		limit:   limit,
+		rate:    float64(limit) / period.Seconds(),
+		maxKeys: maxKeys,
+		buckets: map[string]*list.Element{},
+		lru:     list.New(),
+	}
+}
+
+// Take takes a token for the given key.
+func (s *MemoryRateLimitStore) Take(_ context.Context, key string) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+
+	var b *bucket
+	if elem, ok := s.buckets[key]; ok {
+		s.lru.MoveToFront(elem)
+		b = elem.Value.(*bucket)
+		b.tokens = min(b.tokens+now.Sub(b.last).Seconds()*s.rate, float64(s.limit))
+		b.last = now
+	} else {
+		b = &bucket{key: key, tokens: float64(s.limit), last: now}
+		s.buckets[key] = s.lru.PushFront(b)
+		s.evict()
+	}
+
+	res := RateLimitResult{Limit: s.limit}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = s.duration(1 - b.tokens)
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = s.duration(float64(s.limit) - b.tokens)
+	return res, nil
+}
+
+func (s *MemoryRateLimitStore) evict() {
+	for s.maxKeys > 0 && s.lru.Len() > s.maxKeys {
+		elem := s.lru.Back()
+		s.lru.Remove(elem)
+		delete(s.buckets, elem.Value.(*bucket).key)
+	}
+}
+
+func (s *MemoryRateLimitStore) duration(tokens float64) time.Duration {
+	return time.Duration(tokens / s.rate * float64(time.Second))
+}
+
+func rateLimitKey(req *http.Request) string {
+	if ip, ok := request.ClientIPFrom(req.Context()); ok {
+		return ip.String()
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
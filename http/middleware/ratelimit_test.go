@@ -0,0 +1,172 @@
+package middleware_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	httpx "github.com/hamba/pkg/v2/http"
+	"github.com/hamba/pkg/v2/http/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimit(t *testing.T) {
+	t.Parallel()
+
+	store := middleware.NewMemoryRateLimitStore(2, time.Hour, 10)
+
+	next := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	h := middleware.RateLimit(store)(next)
+
+	codes := make([]int, 3)
+	var rec *httptest.ResponseRecorder
+	for i := range codes {
+		req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil)
+		rec = httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		codes[i] = rec.Code
+	}
+
+	assert.Equal(t, []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests}, codes)
+	assert.Equal(t, "2", rec.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "3600", rec.Header().Get("RateLimit-Reset"))
+	assert.Equal(t, "1800", rec.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{"code":429,"error":"too many requests"}`, rec.Body.String())
+}
+
+func TestRateLimit_Key(t *testing.T) {
+	t.Parallel()
+
+	store := middleware.NewMemoryRateLimitStore(1, time.Hour, 10)
+
+	next := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	h := middleware.RateLimit(store, middleware.RateLimitKey(func(req *http.Request) string {
+		return req.Header.Get("X-Api-Key")
+	}))(next)
+
+	var codes []int
+	for _, key := range []string{"a", "b", "a"} {
+		req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil)
+		req.Header.Set("X-Api-Key", key)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		codes = append(codes, rec.Code)
+	}
+
+	assert.Equal(t, []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests}, codes)
+}
+
+func TestRateLimit_DefaultKey(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := httpx.NewRealIPResolver("192.0.2.0/24")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		mw        func(http.Handler) http.Handler
+		wantCodes []int
+	}{
+		{
+			name:      "ignores forwarding headers",
+			mw:        func(h http.Handler) http.Handler { return h },
+			wantCodes: []int{http.StatusOK, http.StatusTooManyRequests},
+		},
+		{
+			name:      "uses resolved client IP",
+			mw:        middleware.RealIP(resolver),
+			wantCodes: []int{http.StatusOK, http.StatusOK},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			store := middleware.NewMemoryRateLimitStore(1, time.Hour, 10)
+
+			next := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+			h := test.mw(middleware.RateLimit(store)(next))
+
+			var codes []int
+			for _, ip := range []string{"203.0.113.1", "203.0.113.2"} {
+				req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil)
+				req.Header.Set("X-Forwarded-For", ip)
+				rec := httptest.NewRecorder()
+
+				h.ServeHTTP(rec, req)
+
+				codes = append(codes, rec.Code)
+			}
+
+			assert.Equal(t, test.wantCodes, codes)
+		})
+	}
+}
+
+func TestRateLimit_StoreError(t *testing.T) {
+	t.Parallel()
+
+	var nextCalled bool
+	next := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		nextCalled = true
+	})
+	h := middleware.RateLimit(errorStore{})(next)
+
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, req)
+
+	assert.True(t, nextCalled)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("RateLimit-Limit"))
+}
+
+func TestMemoryRateLimitStore_EvictsLeastRecentlyUsed(t *testing.T) {
+	t.Parallel()
+
+	store := middleware.NewMemoryRateLimitStore(1, time.Hour, 1)
+
+	res, err := store.Take(t.Context(), "a")
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+
+	res, err = store.Take(t.Context(), "b")
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+
+	res, err = store.Take(t.Context(), "a")
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+}
+
+func TestMemoryRateLimitStore_Refills(t *testing.T) {
+	t.Parallel()
+
+	store := middleware.NewMemoryRateLimitStore(1, 10*time.Millisecond, 10)
+
+	res, err := store.Take(t.Context(), "a")
+	require.NoError(t, err)
+	require.True(t, res.Allowed)
+
+	assert.Eventually(t, func() bool {
+		res, err = store.Take(t.Context(), "a")
+		return err == nil && res.Allowed
+	}, time.Second, 5*time.Millisecond)
+}
+
+type errorStore struct{}
+
+func (errorStore) Take(context.Context, string) (middleware.RateLimitResult, error) {
+	return middleware.RateLimitResult{}, errors.New("test error")
+}
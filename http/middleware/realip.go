@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+
+	httpx "github.com/hamba/pkg/v2/http"
+	"github.com/hamba/pkg/v2/http/request"
+)
+
+// RealIPOption represents a RealIP middleware option.
+type RealIPOption func(*realIPConfig)
+
+type realIPConfig struct {
+	rewrite bool
+}
+
+// RealIPRewriteRemoteAddr rewrites the request remote address to the
+// resolved client address, so downstream handlers see the real address.
+func RealIPRewriteRemoteAddr() RealIPOption {
+	return func(c *realIPConfig) {
+		c.rewrite = true
+	}
+}
+
+// WithRealIP resolves the client IP address using the resolver and sets it
+// on the request context.
+//
+// If the client IP address cannot be resolved, the request is passed on
+// unchanged.
+func WithRealIP(h http.Handler, r *httpx.RealIPResolver, opts ...RealIPOption) http.Handler {
+	var cfg realIPConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		addr := r.Resolve(req)
+		if !addr.IsValid() {
+			h.ServeHTTP(rw, req)
+			return
+		}
+
+		req = req.WithContext(request.WithClientIP(req.Context(), addr))
+		if cfg.rewrite {
+			remoteAddr := addr.String()
+			if _, port, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+				remoteAddr = net.JoinHostPort(remoteAddr, port)
+			}
+			req.RemoteAddr = remoteAddr
+		}
+
+		h.ServeHTTP(rw, req)
+	})
+}
+
+// RealIP is a wrapper for WithRealIP.
+func RealIP(r *httpx.RealIPResolver, opts ...RealIPOption) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return WithRealIP(next, r, opts...)
+	}
+}
@@ -0,0 +1,101 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	httpx "github.com/hamba/pkg/v2/http"
+	"github.com/hamba/pkg/v2/http/middleware"
+	"github.com/hamba/pkg/v2/http/request"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRealIP(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		opts           []middleware.RealIPOption
+		remoteAddr     string
+		wantIP         string
+		wantRemoteAddr string
+	}{
+		{
+			name:           "sets client ip",
+			remoteAddr:     "10.0.0.1:1234",
+			wantIP:         "1.2.3.4",
+			wantRemoteAddr: "10.0.0.1:1234",
+		},
+		{
+			name:           "rewrites remote addr",
+			opts:           []middleware.RealIPOption{middleware.RealIPRewriteRemoteAddr()},
+			remoteAddr:     "10.0.0.1:1234",
+			wantIP:         "1.2.3.4",
+			wantRemoteAddr: "1.2.3.4:1234",
+		},
+		{
+			name:           "rewrites remote addr without port",
+			opts:           []middleware.RealIPOption{middleware.RealIPRewriteRemoteAddr()},
+			remoteAddr:     "10.0.0.1",
+			wantIP:         "1.2.3.4",
+			wantRemoteAddr: "1.2.3.4",
+		},
+	}
+
+	r, err := httpx.NewRealIPResolver("10.0.0.0/8")
+	require.NoError(t, err)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			var nextCalled bool
+			next := http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+				nextCalled = true
+
+				got, ok := request.ClientIPFrom(req.Context())
+
+				assert.True(t, ok)
+				assert.Equal(t, netip.MustParseAddr(test.wantIP), got)
+				assert.Equal(t, test.wantRemoteAddr, req.RemoteAddr)
+			})
+
+			req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil)
+			req.RemoteAddr = test.remoteAddr
+			req.Header.Set("X-Forwarded-For", "1.2.3.4")
+			rec := httptest.NewRecorder()
+
+			middleware.RealIP(r, test.opts...)(next).ServeHTTP(rec, req)
+
+			assert.True(t, nextCalled)
+		})
+	}
+}
+
+func TestRealIP_UnresolvableAddress(t *testing.T) {
+	t.Parallel()
+
+	r, err := httpx.NewRealIPResolver()
+	require.NoError(t, err)
+
+	var nextCalled bool
+	next := http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+		nextCalled = true
+
+		_, ok := request.ClientIPFrom(req.Context())
+
+		assert.False(t, ok)
+		assert.Equal(t, "@", req.RemoteAddr)
+	})
+
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil)
+	req.RemoteAddr = "@"
+	rec := httptest.NewRecorder()
+
+	middleware.RealIP(r, middleware.RealIPRewriteRemoteAddr())(next).ServeHTTP(rec, req)
+
+	assert.True(t, nextCalled)
+}
@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ResponseWrapper wraps a response writer, recording the status code,
+// the number of bytes written and when the response was first written.
+//
+// The wrapper implements http.Flusher, http.Hijacker, http.Pusher and
+// io.ReaderFrom, delegating to the underlying writer when it supports
+// them, and can be unwrapped by http.ResponseController.
+type ResponseWrapper struct {
+	http.ResponseWriter
+
+	status      int
+	bytes       int64
+	wroteHeader bool
+	firstWrite  time.Time
+}
+
+// NewResponseWrapper returns a response wrapper for the response writer.
+func NewResponseWrapper(rw http.ResponseWriter) *ResponseWrapper {
+	return &ResponseWrapper{
+		ResponseWriter: rw,
+		status:         http.StatusOK,
+	}
+}
+
+// Write writes the data to the connection as part of an HTTP reply.
+func (rw *ResponseWrapper) Write(p []byte) (int, error) {
+	rw.markWritten()
+	n, err := rw.ResponseWriter.Write(p)
+	rw.bytes += int64(n)
+	return n, err
+}
+
+// WriteHeader sends an HTTP response header with status code.
+//
+// Informational responses, except `101 Switching Protocols`, are passed
+// on without being recorded. Superfluous calls do not change the status.
+func (rw *ResponseWrapper) WriteHeader(code int) {
+	switch {
+	case rw.wroteHeader:
+	case code >= 100 && code <= 199 && code != http.StatusSwitchingProtocols:
+	default:
+		rw.status = code
+		rw.markWritten()
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+// ReadFrom reads data from r until EOF or error, writing it to the
+// response. The underlying io.ReaderFrom is used when available,
+// allowing the server to use sendfile.
+func (rw *ResponseWrapper) ReadFrom(r io.Reader) (int64, error) {
+	rw.markWritten()
+
+	var (
+		n   int64
+		err error
+	)
+	if rf, ok := rw.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(r)
+	} else {
+		n, err = io.Copy(rw.ResponseWriter, r)
+	}
+	rw.bytes += n
+	return n, err
+}
+
+// Flush sends any buffered data to the client.
+func (rw *ResponseWrapper) Flush() {
+	_ = rw.FlushError()
+}
+
+// FlushError sends any buffered data to the client, returning any error.
+//
+// If the underlying writer cannot flush, http.ErrNotSupported is returned.
+func (rw *ResponseWrapper) FlushError() error {
+	if err := http.NewResponseController(rw.ResponseWriter).Flush(); err != nil {
+		return err
+	}
+	rw.markWritten()
+	return nil
+}
+
+// Push initiates an HTTP/2 server push.
+//
+// If the underlying writer does not support push, http.ErrNotSupported
+// is returned.
+func (rw *ResponseWrapper) Push(target string, opts *http.PushOptions) error {
+	p, ok := rw.ResponseWriter.(http.Pusher)
+	if !ok {
+		return http.ErrNotSupported
+	}
+	return p.Push(target, opts)
+}
+
+// Hijack returns a hijacked connection or an error.
+//
+// This is required by some websocket libraries.
+func (rw *ResponseWrapper) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijacker not supported")
+	}
+	conn, brw, err := h.Hijack()
+	if err == nil {
+		rw.markWritten()
+	}
+	return conn, brw, err
+}
+
+// Unwrap returns the underlying response writer.
+// This is used by http.ResponseController to find the first
+// response writer that implements an interface.
+func (rw *ResponseWrapper) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// WroteHeader returns true if the response header has been written.
+func (rw *ResponseWrapper) WroteHeader() bool {
+	return rw.wroteHeader
+}
+
+// Status returns the status code of the response.
+//
+// If the header has not been written, `200 OK` is returned.
+func (rw *ResponseWrapper) Status() int {
+	return rw.status
+}
+
+// BytesWritten returns the number of bytes written to the writer.
+func (rw *ResponseWrapper) BytesWritten() int64 {
+	return rw.bytes
+}
+
+// FirstWrite returns the time the response header or body was first
+// written, or the zero time if nothing has been written.
+func (rw *ResponseWrapper) FirstWrite() time.Time {
+	return rw.firstWrite
+}
+
+func (rw *ResponseWrapper) markWritten() {
+	if !rw.wroteHeader {
+		rw.wroteHeader = true
+		rw.firstWrite = time.Now()
+	}
+}
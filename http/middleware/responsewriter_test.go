@@ -0,0 +1,238 @@
+package middleware_test
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hamba/pkg/v2/http/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseWrapper(t *testing.T) {
+	t.Parallel()
+
+	base := newBaseWriter()
+	wrap := middleware.NewResponseWrapper(base)
+
+	assert.False(t, wrap.WroteHeader())
+	assert.True(t, wrap.FirstWrite().IsZero())
+
+	wrap.WriteHeader(http.StatusEarlyHints)
+	assert.False(t, wrap.WroteHeader())
+
+	wrap.WriteHeader(http.StatusCreated)
+	wrap.WriteHeader(http.StatusBadRequest)
+	_, err := wrap.Write([]byte("test"))
+	require.NoError(t, err)
+
+	assert.True(t, wrap.WroteHeader())
+	assert.False(t, wrap.FirstWrite().IsZero())
+	assert.Equal(t, http.StatusCreated, wrap.Status())
+	assert.Equal(t, int64(4), wrap.BytesWritten())
+	assert.Equal(t, []int{http.StatusEarlyHints, http.StatusCreated, http.StatusBadRequest}, base.codes)
+	assert.Equal(t, "test", base.buf.String())
+}
+
+func TestResponseWrapper_SwitchingProtocols(t *testing.T) {
+	t.Parallel()
+
+	wrap := middleware.NewResponseWrapper(httptest.NewRecorder())
+
+	wrap.WriteHeader(http.StatusSwitchingProtocols)
+
+	assert.True(t, wrap.WroteHeader())
+	assert.Equal(t, http.StatusSwitchingProtocols, wrap.Status())
+}
+
+func TestResponseWrapper_Interfaces(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		rw       func() (http.ResponseWriter, *baseWriter)
+		flush    bool
+		hijack   bool
+		push     bool
+		readFrom bool
+	}{
+		{
+			name: "none",
+			rw: func() (http.ResponseWriter, *baseWriter) {
+				b := newBaseWriter()
+				return b, b
+			},
+		},
+		{
+			name: "flusher",
+			rw: func() (http.ResponseWriter, *baseWriter) {
+				b := newBaseWriter()
+				return flushWriter{b}, b
+			},
+			flush: true,
+		},
+		{
+			name: "hijacker",
+			rw: func() (http.ResponseWriter, *baseWriter) {
+				b := newBaseWriter()
+				return hijackWriter{b}, b
+			},
+			hijack: true,
+		},
+		{
+			name: "pusher",
+			rw: func() (http.ResponseWriter, *baseWriter) {
+				b := newBaseWriter()
+				return pushWriter{b}, b
+			},
+			push: true,
+		},
+		{
+			name: "reader from",
+			rw: func() (http.ResponseWriter, *baseWriter) {
+				b := newBaseWriter()
+				return readFromWriter{b}, b
+			},
+			readFrom: true,
+		},
+		{
+			name: "all",
+			rw: func() (http.ResponseWriter, *baseWriter) {
+				b := newBaseWriter()
+				return allWriter{b}, b
+			},
+			flush:    true,
+			hijack:   true,
+			push:     true,
+			readFrom: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			t.Run("flush", func(t *testing.T) {
+				rw, base := test.rw()
+				wrap := middleware.NewResponseWrapper(rw)
+
+				err := http.NewResponseController(wrap).Flush()
+
+				if !test.flush {
+					assert.ErrorIs(t, err, http.ErrNotSupported)
+					assert.False(t, wrap.WroteHeader())
+					return
+				}
+				require.NoError(t, err)
+				assert.True(t, base.flushed)
+				assert.True(t, wrap.WroteHeader())
+			})
+
+			t.Run("hijack", func(t *testing.T) {
+				rw, base := test.rw()
+				wrap := middleware.NewResponseWrapper(rw)
+
+				_, _, err := wrap.Hijack()
+
+				if !test.hijack {
+					assert.Error(t, err)
+					return
+				}
+				require.NoError(t, err)
+				assert.True(t, base.hijacked)
+			})
+
+			t.Run("push", func(t *testing.T) {
+				rw, base := test.rw()
+				wrap := middleware.NewResponseWrapper(rw)
+
+				err := wrap.Push("/app.js", nil)
+
+				if !test.push {
+					assert.ErrorIs(t, err, http.ErrNotSupported)
+					return
+				}
+				require.NoError(t, err)
+				assert.True(t, base.pushed)
+			})
+
+			t.Run("read from", func(t *testing.T) {
+				rw, base := test.rw()
+				wrap := middleware.NewResponseWrapper(rw)
+
+				// A limited reader does not implement io.WriterTo, so io.Copy uses ReadFrom.
+				n, err := io.Copy(wrap, io.LimitReader(strings.NewReader("test"), 10))
+
+				require.NoError(t, err)
+				assert.Equal(t, int64(4), n)
+				assert.Equal(t, int64(4), wrap.BytesWritten())
+				assert.Equal(t, "test", base.buf.String())
+				assert.Equal(t, test.readFrom, base.readFrom)
+			})
+		})
+	}
+}
+
+type baseWriter struct {
+	hdr   http.Header
+	buf   bytes.Buffer
+	codes []int
+
+	flushed  bool
+	hijacked bool
+	pushed   bool
+	readFrom bool
+}
+
+func newBaseWriter() *baseWriter {
+	return &baseWriter{hdr: http.Header{}}
+}
+
+func (w *baseWriter) Header() http.Header { return w.hdr }
+
+func (w *baseWriter) Write(p []byte) (int, error) { return w.buf.Write(p) }
+
+func (w *baseWriter) WriteHeader(code int) { w.codes = append(w.codes, code) }
+
+type flushWriter struct{ *baseWriter }
+
+func (w flushWriter) Flush() { w.flushed = true }
+
+type hijackWriter struct{ *baseWriter }
+
+func (w hijackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.hijacked = true
+	return nil, nil, nil
+}
+
+type pushWriter struct{ *baseWriter }
+
+func (w pushWriter) Push(string, *http.PushOptions) error {
+	w.pushed = true
+	return nil
+}
+
+type readFromWriter struct{ *baseWriter }
+
+func (w readFromWriter) ReadFrom(r io.Reader) (int64, error) {
+	w.readFrom = true
+	return w.buf.ReadFrom(r)
+}
+
+type allWriter struct{ *baseWriter }
+
+func (w allWriter) Flush() { flushWriter(w).Flush() }
+
+func (w allWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) { return hijackWriter(w).Hijack() }
+
+func (w allWriter) Push(target string, opts *http.PushOptions) error {
+	return pushWriter(w).Push(target, opts)
+}
+
+func (w allWriter) ReadFrom(r io.Reader) (int64, error) { return readFromWriter(w).ReadFrom(r) }
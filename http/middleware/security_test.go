@@ -0,0 +1,116 @@
+package middleware_test
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hamba/pkg/v2/http/middleware"
+	"github.com/hamba/pkg/v2/http/request"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecurityHeaders_API(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		tls      bool
+		wantHSTS string
+	}{
+		{
+			name:     "with tls",
+			tls:      true,
+			wantHSTS: "max-age=63072000; includeSubDomains",
+		},
+		{
+			name:     "without tls",
+			wantHSTS: "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			next := http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+				_, ok := request.CSPNonceFrom(req.Context())
+				assert.False(t, ok)
+			})
+
+			req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil)
+			if test.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
+			rec := httptest.NewRecorder()
+
+			middleware.SecurityHeaders(middleware.APISecurityPolicy())(next).ServeHTTP(rec, req)
+
+			hdr := rec.Header()
+			assert.Equal(t, test.wantHSTS, hdr.Get("Strict-Transport-Security"))
+			assert.Equal(t, "default-src 'none'; frame-ancestors 'none'", hdr.Get("Content-Security-Policy"))
+			assert.Equal(t, "nosniff", hdr.Get("X-Content-Type-Options"))
+			assert.Equal(t, "DENY", hdr.Get("X-Frame-Options"))
+			assert.Equal(t, "no-referrer", hdr.Get("Referrer-Policy"))
+			assert.Equal(t, "same-origin", hdr.Get("Cross-Origin-Opener-Policy"))
+			assert.Equal(t, "require-corp", hdr.Get("Cross-Origin-Embedder-Policy"))
+			assert.Equal(t, "same-origin", hdr.Get("Cross-Origin-Resource-Policy"))
+			assert.Empty(t, hdr.Get("Permissions-Policy"))
+		})
+	}
+}
+
+func TestSecurityHeaders_Nonce(t *testing.T) {
+	t.Parallel()
+
+	var nonces []string
+	next := http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+		nonce, ok := request.CSPNonceFrom(req.Context())
+		require.True(t, ok)
+
+		nonces = append(nonces, nonce)
+	})
+
+	h := middleware.SecurityHeaders(middleware.BrowserSecurityPolicy())(next)
+
+	var csps []string
+	for range 2 {
+		req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		csps = append(csps, rec.Header().Get("Content-Security-Policy"))
+		assert.Equal(t, "camera=(), microphone=(), geolocation=()", rec.Header().Get("Permissions-Policy"))
+	}
+
+	require.Len(t, nonces, 2)
+	assert.NotEqual(t, nonces[0], nonces[1])
+	assert.Len(t, nonces[0], 24)
+	want := "default-src 'self'; script-src 'self' 'nonce-" + nonces[0] + "'; style-src 'self' 'nonce-" + nonces[0] +
+		"'; object-src 'none'; base-uri 'self'; frame-ancestors 'self'"
+	assert.Equal(t, want, csps[0])
+}
+
+func TestSecurityHeaders_Custom(t *testing.T) {
+	t.Parallel()
+
+	next := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil)
+	req.TLS = &tls.ConnectionState{}
+	rec := httptest.NewRecorder()
+
+	p := middleware.SecurityPolicy{
+		HSTSMaxAge:  time.Minute,
+		HSTSPreload: true,
+	}
+	middleware.SecurityHeaders(p)(next).ServeHTTP(rec, req)
+
+	assert.Equal(t, "max-age=60; preload", rec.Header().Get("Strict-Transport-Security"))
+	assert.Empty(t, rec.Header().Get("X-Content-Type-Options"))
+	assert.Empty(t, rec.Header().Get("Content-Security-Policy"))
+}
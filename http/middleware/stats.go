@@ -0,0 +1,329 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hamba/statter/v2"
+	"github.com/hamba/statter/v2/reporter/prometheus"
+	"github.com/hamba/statter/v2/tags"
+)
+
+// Handler names used when the handler name cannot be derived.
+const (
+	// OtherHandler is the handler name used once the maximum number of
+	// distinct handler names has been reached.
+	OtherHandler = "other"
+
+	// AllHandlers is the handler name of the `requests` counter and
+	// the `inflight` gauge when the handler is only known after routing.
+	AllHandlers = "all"
+)
+
+// StatsOption represents a Stats middleware option.
+type StatsOption func(*statsConfig)
+
+type statsConfig struct {
+	normalize       func(string) string
+	maxHandlers     int
+	prefix          string
+	tags            []statter.Tag
+	sizeBuckets     []float64
+	reqSizeBuckets  []float64
+	durationBuckets []float64
+}
+
+// StatsPathNormalizer sets the function used to derive the handler name
+// from the request path when no name or route pattern is available.
+//
+// The default normalizer is NormalizePath.
+func StatsPathNormalizer(fn func(path string) string) StatsOption {
+	return func(c *statsConfig) {
+		c.normalize = fn
+	}
+}
+
+// StatsMaxHandlers sets the maximum number of distinct derived handler
+// names. Once reached, new handler names are reported as OtherHandler.
+// A value of zero or less disables the limit.
+//
+// The default maximum is 100.
+func StatsMaxHandlers(n int) StatsOption {
+	return func(c *statsConfig) {
+		c.maxHandlers = n
+	}
+}
+
+// StatsPrefix sets the prefix of the metric names.
+func StatsPrefix(prefix string) StatsOption {
+	return func(c *statsConfig) {
+		c.prefix = prefix
+	}
+}
+
+// StatsTags sets static tags that are added to all metrics.
+func StatsTags(tags ...statter.Tag) StatsOption {
+	return func(c *statsConfig) {
+		c.tags = tags
+	}
+}
+
+// StatsSizeBuckets sets the buckets of the `response.size` histogram in bytes.
+//
+// The default buckets are `[200, 500, 900, 1500, 5000, 10000]`. The buckets
+// are registered once per statter, so the buckets of a later WithStats
+// on the same statter are ignored.
+func StatsSizeBuckets(buckets ...float64) StatsOption {
+	return func(c *statsConfig) {
+		c.sizeBuckets = buckets
+	}
+}
+
+// StatsRequestSizeBuckets sets the buckets of the `request.size` histogram in bytes.
+//
+// The default buckets are the same as the response size buckets. As with
+// StatsSizeBuckets, only the buckets of the first WithStats on a statter are used.
+func StatsRequestSizeBuckets(buckets ...float64) StatsOption {
+	return func(c *statsConfig) {
+		c.reqSizeBuckets = buckets
+	}
+}
+
+// StatsDurationBuckets sets the buckets of the `response.duration` and
+// `response.ttfb` timings in seconds.
+//
+// By default the buckets of the reporter are used. As with StatsSizeBuckets,
+// only the buckets of the first WithStats on a statter are used.
+func StatsDurationBuckets(buckets ...float64) StatsOption {
+	return func(c *statsConfig) {
+		c.durationBuckets = buckets
+	}
+}
+
+// WithStats collects statistics about HTTP requests.
+//
+// If name is empty, the handler name of the response statistics is derived
+// from the request once it has been served. The http.ServeMux route pattern
+// is used when available, otherwise the request path is normalized. Requests
+// not matched by a wrapped ServeMux are reported as OtherHandler.
+//
+// The `requests` counter and the `inflight` gauge are recorded before the
+// request is served. As this is before routing, they are only tagged with the
+// handler name or the route pattern when WithStats is registered on a
+// ServeMux. Otherwise, e.g. when wrapping a ServeMux, all requests are tagged
+// as AllHandlers.
+// The `request.size` histogram observes the bytes of the request body read by
+// the handler, and the `response.ttfb` timing the time until the response
+// header or first byte was written.
+func WithStats(name string, s *statter.Statter, h http.Handler, opts ...StatsOption) http.Handler {
+	cfg := statsConfig{
+		normalize:   NormalizePath,
+		maxHandlers: 100,
+		sizeBuckets: []float64{200, 500, 900, 1500, 5000, 10000},
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.reqSizeBuckets == nil {
+		cfg.reqSizeBuckets = cfg.sizeBuckets
+	}
+
+	if cfg.prefix != "" || len(cfg.tags) > 0 {
+		s = s.With(cfg.prefix, cfg.tags...)
+	}
+	registerStats(s, &cfg)
+
+	handlers := &handlerNames{max: cfg.maxHandlers, names: map[string]struct{}{}}
+
+	_, isMux := h.(*http.ServeMux)
+
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		entryName := name
+		switch {
+		case entryName != "":
+		case req.Pattern != "":
+			entryName = routeName(req, cfg.normalize)
+		default:
+			entryName = AllHandlers
+		}
+		s.Counter("requests", tags.Str("handler", entryName), tags.Str("method", req.Method)).Inc(1)
+
+		inflight := s.Gauge("inflight", tags.Str("handler", entryName))
+		inflight.Inc()
+		defer inflight.Dec()
+
+		var body *countingBody
+		if req.Body != nil && req.Body != http.NoBody {
+			body = &countingBody{ReadCloser: req.Body}
+			req.Body = body
+		}
+
+		wrap := NewResponseWrapper(rw)
+
+		start := time.Now()
+		h.ServeHTTP(wrap, req)
+		dur := time.Since(start)
+
+		handler := name
+		switch {
+		case handler != "":
+		case isMux && req.Pattern == "":
+			handler = OtherHandler
+		default:
+			handler = handlers.get(routeName(req, cfg.normalize))
+		}
+
+		t := make([]statter.Tag, 2, 4)
+		t[0] = tags.Str("handler", handler)
+		t[1] = tags.Str("method", req.Method)
+		t = append(t, tags.StatusCode("code-group", wrap.Status()))
+		t = append(t, tags.Int("code", wrap.Status()))
+		s.Counter("responses", t...).Inc(1)
+		var reqSize int64
+		if body != nil {
+			reqSize = body.n.Load()
+		}
+		s.Histogram("request.size", t...).Observe(float64(reqSize))
+		s.Histogram("response.size", t...).Observe(float64(wrap.BytesWritten()))
+		s.Timing("response.duration", t...).Observe(dur)
+		if first := wrap.FirstWrite(); !first.IsZero() {
+			s.Timing("response.ttfb", t...).Observe(first.Sub(start))
+		}
+	})
+}
+
+// Stats is a wrapper for WithStats.
+func Stats(name string, s *statter.Statter, opts ...StatsOption) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return WithStats(name, s, next, opts...)
+	}
+}
+
+var labelReplacer = strings.NewReplacer(".", "_", "-", "_")
+
+// registerStats registers the histograms with their buckets on the statter.
+//
+// The reporter only registers a histogram once, so the buckets of the first
+// registration are used. The timing buckets follow the same rule.
+func registerStats(s *statter.Statter, cfg *statsConfig) {
+	// Label names must be formatted as the Prometheus reporter formats tags.
+	lblNames := []string{"handler", "method", "code", "code_group"}
+	for _, tag := range cfg.tags {
+		lblNames = append(lblNames, labelReplacer.Replace(tag[0]))
+	}
+
+	histograms := []struct {
+		name    string
+		buckets []float64
+		help    string
+	}{
+		{name: "request.size", buckets: cfg.reqSizeBuckets, help: "The size of a request body in bytes"},
+		{name: "response.size", buckets: cfg.sizeBuckets, help: "The size of a response in bytes"},
+	}
+	first := true
+	for _, h := range histograms {
+		if !prometheus.RegisterHistogram(s, h.name, slices.Clone(lblNames), h.buckets, h.help) {
+			first = false
+		}
+	}
+
+	if !first || len(cfg.durationBuckets) == 0 {
+		return
+	}
+	for _, name := range []string{"response.duration", "response.ttfb"} {
+		// Timings can only be bucketed by name.
+		prometheus.SetMetricBuckets(s, name, cfg.durationBuckets) //nolint:staticcheck
+	}
+}
+
+// countingBody counts the bytes read from a request body.
+type countingBody struct {
+	io.ReadCloser
+
+	n atomic.Int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n.Add(int64(n))
+	return n, err
+}
+
+var uuidRegexp = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// NormalizePath replaces numeric and UUID path segments with `{id}`,
+// e.g. `/users/123` becomes `/users/{id}`.
+func NormalizePath(path string) string {
+	segs := strings.Split(path, "/")
+	for i, seg := range segs {
+		if isNumeric(seg) || uuidRegexp.MatchString(seg) {
+			segs[i] = "{id}"
+		}
+	}
+	return strings.Join(segs, "/")
+}
+
+func isNumeric(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// routeName returns the route pattern of the request without
+// the method, or the normalized request path.
+func routeName(req *http.Request, normalize func(string) string) string {
+	if req.Pattern == "" {
+		return normalize(req.URL.Path)
+	}
+
+	pattern := req.Pattern
+	if i := strings.IndexAny(pattern, " \t"); i >= 0 {
+		pattern = strings.TrimLeft(pattern[i:], " \t")
+	}
+	return pattern
+}
+
+// handlerNames caps the number of distinct handler names.
+type handlerNames struct {
+	max int
+
+	mu    sync.RWMutex
+	names map[string]struct{}
+}
+
+func (h *handlerNames) get(name string) string {
+	if h.max <= 0 {
+		return name
+	}
+
+	h.mu.RLock()
+	_, ok := h.names[name]
+	h.mu.RUnlock()
+	if ok {
+		return name
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok = h.names[name]; ok {
+		return name
+	}
+	if len(h.names) >= h.max {
+		return OtherHandler
+	}
+	h.names[name] = struct{}{}
+	return name
+}
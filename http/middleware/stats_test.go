@@ -0,0 +1,296 @@
+package middleware_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hamba/logger/v2"
+	"github.com/hamba/pkg/v2/http/middleware"
+	"github.com/hamba/statter/v2"
+	"github.com/hamba/statter/v2/reporter/prometheus"
+	"github.com/hamba/statter/v2/tags"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStats_HandlerName(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		mux          string
+		path         string
+		opts         []middleware.StatsOption
+		wantEntryTag string
+		wantRespTag  string
+	}{
+		{
+			name:         "normalizes path",
+			path:         "/users/123/orders/3f2504e0-4f89-11d3-9a0c-0305e82c3301",
+			wantEntryTag: "all",
+			wantRespTag:  "/users/{id}/orders/{id}",
+		},
+		{
+			name:         "custom normalizer",
+			path:         "/users/123",
+			opts:         []middleware.StatsOption{middleware.StatsPathNormalizer(func(string) string { return "custom" })},
+			wantEntryTag: "all",
+			wantRespTag:  "custom",
+		},
+		{
+			name:         "uses pattern inside mux",
+			mux:          "inside",
+			path:         "/items/abc",
+			wantEntryTag: "/items/{name}",
+			wantRespTag:  "/items/{name}",
+		},
+		{
+			name:         "uses pattern when wrapping mux",
+			mux:          "wrap",
+			path:         "/items/abc",
+			wantEntryTag: "all",
+			wantRespTag:  "/items/{name}",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			reqTags := [][2]string{{"handler", test.wantEntryTag}, {"method", "GET"}}
+			respTags := [][2]string{{"handler", test.wantRespTag}, {"method", "GET"}, {"code-group", "2xx"}, {"code", "200"}}
+
+			m := &mockReporter{}
+			m.On("Counter", "requests", int64(1), reqTags)
+			m.On("Gauge", "inflight", float64(0), [][2]string{{"handler", test.wantEntryTag}})
+			m.On("Counter", "responses", int64(1), respTags)
+			m.On("Histogram", "request.size", respTags).Return(func(float64) {})
+			m.On("Histogram", "response.size", respTags).Return(func(float64) {})
+			m.On("Timing", "response.duration", respTags).Return(func(time.Duration) {})
+
+			s := statter.New(m, time.Second)
+
+			noop := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+			var h http.Handler
+			switch test.mux {
+			case "inside":
+				mux := http.NewServeMux()
+				mux.Handle("GET /items/{name}", middleware.WithStats("", s, noop, test.opts...))
+				h = mux
+			case "wrap":
+				mux := http.NewServeMux()
+				mux.Handle("GET /items/{name}", noop)
+				h = middleware.WithStats("", s, mux, test.opts...)
+			default:
+				h = middleware.WithStats("", s, noop, test.opts...)
+			}
+
+			req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, test.path, nil)
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			err := s.Close()
+			require.NoError(t, err)
+
+			m.AssertExpectations(t)
+		})
+	}
+}
+
+func TestStats_MaxHandlers(t *testing.T) {
+	t.Parallel()
+
+	m := &mockReporter{}
+	m.On("Counter", mock.Anything, mock.Anything, mock.Anything)
+	m.On("Gauge", mock.Anything, mock.Anything, mock.Anything)
+	m.On("Histogram", mock.Anything, mock.Anything).Return(func(float64) {})
+	m.On("Timing", mock.Anything, mock.Anything).Return(func(time.Duration) {})
+
+	s := statter.New(m, time.Second)
+
+	h := middleware.WithStats("", s, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}),
+		middleware.StatsMaxHandlers(2),
+	)
+
+	for _, path := range []string{"/a", "/b", "/c", "/a"} {
+		req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, path, nil)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	err := s.Close()
+	require.NoError(t, err)
+
+	m.AssertCalled(t, "Counter", "requests", int64(4), [][2]string{{"handler", "all"}, {"method", "GET"}})
+	m.AssertCalled(t, "Counter", "responses", int64(2), [][2]string{{"handler", "/a"}, {"method", "GET"}, {"code-group", "2xx"}, {"code", "200"}})
+	m.AssertCalled(t, "Counter", "responses", int64(1), [][2]string{{"handler", "/b"}, {"method", "GET"}, {"code-group", "2xx"}, {"code", "200"}})
+	m.AssertCalled(t, "Counter", "responses", int64(1), [][2]string{{"handler", "other"}, {"method", "GET"}, {"code-group", "2xx"}, {"code", "200"}})
+	m.AssertNotCalled(t, "Counter", "responses", mock.Anything, [][2]string{{"handler", "/c"}, {"method", "GET"}, {"code-group", "2xx"}, {"code", "200"}})
+}
+
+func TestStats_MaxHandlersWrappingMux(t *testing.T) {
+	t.Parallel()
+
+	m := &mockReporter{}
+	m.On("Counter", mock.Anything, mock.Anything, mock.Anything)
+	m.On("Gauge", mock.Anything, mock.Anything, mock.Anything)
+	m.On("Histogram", mock.Anything, mock.Anything).Return(func(float64) {})
+	m.On("Timing", mock.Anything, mock.Anything).Return(func(time.Duration) {})
+
+	s := statter.New(m, time.Second)
+
+	noop := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	mux := http.NewServeMux()
+	mux.Handle("GET /files/{name}", noop)
+	mux.Handle("GET /health", noop)
+
+	h := middleware.WithStats("", s, mux, middleware.StatsMaxHandlers(2))
+
+	paths := []string{"/unknown/1", "/unknown/2", "/unknown/3"}
+	for i := range 10 {
+		paths = append(paths, "/files/f"+strconv.Itoa(i)+".txt")
+	}
+	paths = append(paths, "/health")
+	for _, path := range paths {
+		req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, path, nil)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	err := s.Close()
+	require.NoError(t, err)
+
+	m.AssertCalled(t, "Counter", "requests", int64(14), [][2]string{{"handler", "all"}, {"method", "GET"}})
+	m.AssertCalled(t, "Counter", "responses", int64(10), [][2]string{{"handler", "/files/{name}"}, {"method", "GET"}, {"code-group", "2xx"}, {"code", "200"}})
+	m.AssertCalled(t, "Counter", "responses", int64(1), [][2]string{{"handler", "/health"}, {"method", "GET"}, {"code-group", "2xx"}, {"code", "200"}})
+	m.AssertCalled(t, "Counter", "responses", int64(3), [][2]string{{"handler", "other"}, {"method", "GET"}, {"code-group", "4xx"}, {"code", "404"}})
+}
+
+func TestStats_CountsPanickingRequests(t *testing.T) {
+	t.Parallel()
+
+	m := &mockReporter{}
+	m.On("Counter", mock.Anything, mock.Anything, mock.Anything)
+	m.On("Gauge", mock.Anything, mock.Anything, mock.Anything)
+
+	s := statter.New(m, time.Second)
+
+	log := logger.New(io.Discard, logger.LogfmtFormat(), logger.Info)
+	h := middleware.Recovery(log)(middleware.WithStats("test", s, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("panic text")
+	})))
+
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	err := s.Close()
+	require.NoError(t, err)
+
+	m.AssertCalled(t, "Counter", "requests", int64(1), [][2]string{{"handler", "test"}, {"method", "GET"}})
+	m.AssertCalled(t, "Gauge", "inflight", float64(0), [][2]string{{"handler", "test"}})
+}
+
+func TestNormalizePath(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "/", want: "/"},
+		{path: "/users", want: "/users"},
+		{path: "/users/42", want: "/users/{id}"},
+		{path: "/users/42/posts/v2", want: "/users/{id}/posts/v2"},
+		{path: "/orders/3F2504E0-4F89-11D3-9A0C-0305E82C3301/", want: "/orders/{id}/"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, test.want, middleware.NormalizePath(test.path))
+		})
+	}
+}
+
+func TestStats_PrometheusOptions(t *testing.T) {
+	t.Parallel()
+
+	var errs []string
+	reporter := prometheus.New("test", prometheus.WithErrorLog(func(msg string) {
+		errs = append(errs, msg)
+	}))
+	s := statter.New(reporter, time.Second)
+
+	h := middleware.WithStats("test-handler", s, http.HandlerFunc(
+		func(w http.ResponseWriter, req *http.Request) {
+			_, _ = io.Copy(io.Discard, req.Body)
+			_, _ = w.Write([]byte("hello"))
+		}),
+		middleware.StatsPrefix("http"),
+		middleware.StatsTags(tags.Str("service", "api")),
+		middleware.StatsSizeBuckets(10, 100),
+		middleware.StatsRequestSizeBuckets(1, 2),
+		middleware.StatsDurationBuckets(0.5, 1),
+	)
+	// The buckets of a later registration on the same statter are ignored.
+	_ = middleware.WithStats("other-handler", s, http.NotFoundHandler(),
+		middleware.StatsPrefix("http"),
+		middleware.StatsTags(tags.Str("service", "api")),
+		middleware.StatsSizeBuckets(1000),
+		middleware.StatsDurationBuckets(5),
+	)
+
+	for range 2 {
+		req := httptest.NewRequestWithContext(t.Context(), http.MethodPost, "/foobar", strings.NewReader("body"))
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	err := s.Close()
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/metrics", nil)
+	reporter.Handler().ServeHTTP(rec, req)
+
+	body := rec.Body.String()
+	assert.Empty(t, errs)
+	assert.Contains(t, body, `test_http_response_size_bucket{code="200",code_group="2xx",handler="test-handler",method="POST",service="api",le="10"} 2`)
+	assert.Contains(t, body, `test_http_request_size_bucket{code="200",code_group="2xx",handler="test-handler",method="POST",service="api",le="2"} 0`)
+	assert.Contains(t, body, `test_http_response_duration_bucket{code="200",code_group="2xx",handler="test-handler",method="POST",service="api",le="0.5"} 2`)
+	assert.Contains(t, body, `test_http_response_ttfb_bucket{code="200",code_group="2xx",handler="test-handler",method="POST",service="api",le="0.5"} 2`)
+	assert.Contains(t, body, `test_http_inflight{handler="test-handler",service="api"} 0`)
+	assert.NotContains(t, body, `le="1000"`)
+	assert.NotContains(t, body, `le="5"`)
+}
+
+func TestStats_RequestSize(t *testing.T) {
+	t.Parallel()
+
+	var reqSize float64
+	m := &mockReporter{}
+	m.On("Counter", mock.Anything, mock.Anything, mock.Anything)
+	m.On("Gauge", mock.Anything, mock.Anything, mock.Anything)
+	m.On("Histogram", "request.size", mock.Anything).Return(func(v float64) { reqSize = v })
+	m.On("Histogram", "response.size", mock.Anything).Return(func(float64) {})
+	m.On("Timing", mock.Anything, mock.Anything).Return(func(time.Duration) {})
+
+	s := statter.New(m, time.Second)
+
+	h := middleware.WithStats("test", s, http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+		_, _ = io.Copy(io.Discard, req.Body)
+	}))
+
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodPost, "/", io.NopCloser(strings.NewReader("hello")))
+	req.ContentLength = -1
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	err := s.Close()
+	require.NoError(t, err)
+
+	assert.InDelta(t, 5.0, reqSize, 0)
+	m.AssertNotCalled(t, "Timing", "response.ttfb", mock.Anything)
+}
@@ -0,0 +1,246 @@
+package middleware
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hamba/pkg/v2/http/render"
+	"github.com/hamba/statter/v2"
+	"github.com/hamba/statter/v2/tags"
+)
+
+// TimeoutOption represents a Timeout middleware option.
+type TimeoutOption func(*timeoutConfig)
+
+type timeoutConfig struct {
+	render func(http.ResponseWriter, *http.Request)
+	name   string
+	stats  *statter.Statter
+}
+
+// TimeoutRenderer sets the function used to render the response when
+// the timeout expires.
+//
+// The default renderer writes a JSON service unavailable error.
+func TimeoutRenderer(fn func(http.ResponseWriter, *http.Request)) TimeoutOption {
+	return func(c *timeoutConfig) {
+		c.render = fn
+	}
+}
+
+// TimeoutStats counts expired requests in the `timeouts` counter,
+// tagged with the handler name.
+func TimeoutStats(name string, s *statter.Statter) TimeoutOption {
+	return func(c *timeoutConfig) {
+		c.name = name
+		c.stats = s
+	}
+}
+
+// WithTimeout sets a deadline of d on the request context. If the handler
+// has not returned once the deadline expires, the error response is
+// rendered, unless the handler has already written the response header.
+//
+// The handler is run in its own goroutine and should return when the
+// request context is done. Any writes after the deadline has expired
+// fail with http.ErrHandlerTimeout.
+func WithTimeout(h http.Handler, d time.Duration, opts ...TimeoutOption) http.Handler {
+	cfg := timeoutConfig{
+		render: func(rw http.ResponseWriter, _ *http.Request) {
+			render.JSONError(rw, http.StatusServiceUnavailable, "service unavailable")
+		},
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		ctx, cancel := context.WithTimeout(req.Context(), d)
+		defer cancel()
+		req = req.WithContext(ctx)
+
+		tw := &timeoutWriter{rw: rw, hdr: rw.Header().Clone()}
+
+		doneCh := make(chan struct{})
+		panicCh := make(chan any, 1)
+		go func() {
+			defer func() {
+				if v := recover(); v != nil {
+					panicCh <- v
+				}
+			}()
+
+			h.ServeHTTP(tw, req)
+			close(doneCh)
+		}()
+
+		select {
+		case v := <-panicCh:
+			panic(v)
+		case <-doneCh:
+		case <-ctx.Done():
+		}
+
+		tw.mu.Lock()
+		defer tw.mu.Unlock()
+
+		var finished bool
+		select {
+		case <-doneCh:
+			finished = true
+		default:
+			// Guard against writes from the abandoned handler.
+			tw.timedOut = true
+		}
+
+		expired := errors.Is(ctx.Err(), context.DeadlineExceeded)
+		if finished && !tw.wroteHeader && !expired {
+			// The handler returned without writing, send its headers.
+			dst := rw.Header()
+			for k, v := range tw.hdr {
+				dst[k] = v
+			}
+		}
+
+		if !expired || (finished && tw.wroteHeader) {
+			return
+		}
+
+		if cfg.stats != nil {
+			cfg.stats.Counter("timeouts", tags.Str("handler", cfg.name)).Inc(1)
+		}
+		if !tw.wroteHeader {
+			cfg.render(rw, req)
+		}
+	})
+}
+
+// Timeout is a wrapper for WithTimeout.
+func Timeout(d time.Duration, opts ...TimeoutOption) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return WithTimeout(next, d, opts...)
+	}
+}
+
+// timeoutWriter guards the response writer against writes after the
+// timeout has expired.
+type timeoutWriter struct {
+	rw  http.ResponseWriter
+	hdr http.Header
+
+	mu          sync.Mutex
+	timedOut    bool
+	wroteHeader bool
+}
+
+// Header returns the header map that will be sent by WriteHeader.
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.hdr
+}
+
+// Write writes the data to the connection as part of an HTTP reply.
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if !tw.wroteHeader {
+		tw.writeHeaderLocked(http.StatusOK)
+	}
+	return tw.rw.Write(p)
+}
+
+// WriteHeader sends an HTTP response header with status code.
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut || tw.wroteHeader {
+		return
+	}
+	tw.writeHeaderLocked(code)
+}
+
+func (tw *timeoutWriter) writeHeaderLocked(code int) {
+	dst := tw.rw.Header()
+	for k, v := range tw.hdr {
+		dst[k] = v
+	}
+	if code >= http.StatusOK {
+		tw.wroteHeader = true
+	}
+	tw.rw.WriteHeader(code)
+}
+
+// Flush sends any buffered data to the client.
+func (tw *timeoutWriter) Flush() {
+	_ = tw.FlushError()
+}
+
+// FlushError sends any buffered data to the client, returning any error.
+func (tw *timeoutWriter) FlushError() error {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut {
+		return http.ErrHandlerTimeout
+	}
+	if !tw.wroteHeader {
+		tw.writeHeaderLocked(http.StatusOK)
+	}
+	return http.NewResponseController(tw.rw).Flush()
+}
+
+// Push initiates an HTTP/2 server push.
+func (tw *timeoutWriter) Push(target string, opts *http.PushOptions) error {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut {
+		return http.ErrHandlerTimeout
+	}
+	p, ok := tw.rw.(http.Pusher)
+	if !ok {
+		return http.ErrNotSupported
+	}
+	return p.Push(target, opts)
+}
+
+// Hijack returns a hijacked connection or an error.
+func (tw *timeoutWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut {
+		return nil, nil, http.ErrHandlerTimeout
+	}
+	h, ok := tw.rw.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijacker not supported")
+	}
+	conn, brw, err := h.Hijack()
+	if err == nil {
+		// The connection is no longer managed by the server.
+		tw.wroteHeader = true
+	}
+	return conn, brw, err
+}
+
+// Unwrap returns the underlying response writer, or nil once the
+// timeout has fired so http.ResponseController cannot reach it.
+func (tw *timeoutWriter) Unwrap() http.ResponseWriter {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut {
+		return nil
+	}
+	return tw.rw
+}
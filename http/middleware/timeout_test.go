@@ -0,0 +1,180 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hamba/pkg/v2/http/middleware"
+	"github.com/hamba/statter/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeout(t *testing.T) {
+	t.Parallel()
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, ok := req.Context().Deadline()
+		assert.True(t, ok)
+
+		rw.Header().Set("X-Test", "test")
+		rw.WriteHeader(http.StatusCreated)
+		_, _ = rw.Write([]byte("test"))
+	})
+
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+
+	middleware.Timeout(time.Second)(next).ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "test", rec.Header().Get("X-Test"))
+	assert.Equal(t, "test", rec.Body.String())
+}
+
+func TestTimeout_OnlyHeaders(t *testing.T) {
+	t.Parallel()
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		rw.Header().Set("X-Test", "test")
+	})
+
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+
+	middleware.Timeout(time.Second)(next).ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "test", rec.Header().Get("X-Test"))
+}
+
+func TestTimeout_Expired(t *testing.T) {
+	t.Parallel()
+
+	m := &mockReporter{}
+	m.On("Counter", "timeouts", int64(1), [][2]string{{"handler", "test"}})
+	s := statter.New(m, time.Second)
+
+	errCh := make(chan error, 1)
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		<-req.Context().Done()
+		// Wait for the timeout response to be written.
+		time.Sleep(10 * time.Millisecond)
+
+		rw.Header().Set("X-Test", "test")
+		_, err := rw.Write([]byte("test"))
+		errCh <- err
+	})
+
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+
+	middleware.Timeout(10*time.Millisecond, middleware.TimeoutStats("test", s))(next).ServeHTTP(rec, req)
+
+	assert.ErrorIs(t, <-errCh, http.ErrHandlerTimeout)
+
+	err := s.Close()
+	require.NoError(t, err)
+
+	m.AssertExpectations(t)
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Empty(t, rec.Header().Get("X-Test"))
+	assert.JSONEq(t, `{"code":503,"error":"service unavailable"}`, rec.Body.String())
+}
+
+func TestTimeout_ExpiredAfterHeaderWritten(t *testing.T) {
+	t.Parallel()
+
+	doneCh := make(chan struct{})
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		defer close(doneCh)
+
+		rw.WriteHeader(http.StatusAccepted)
+		<-req.Context().Done()
+	})
+
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+
+	middleware.Timeout(10*time.Millisecond)(next).ServeHTTP(rec, req)
+	<-doneCh
+
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Empty(t, rec.Body.String())
+}
+
+func TestTimeout_Renderer(t *testing.T) {
+	t.Parallel()
+
+	next := http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+		<-req.Context().Done()
+	})
+	renderer := func(rw http.ResponseWriter, _ *http.Request) {
+		rw.WriteHeader(http.StatusGatewayTimeout)
+	}
+
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+
+	middleware.Timeout(10*time.Millisecond, middleware.TimeoutRenderer(renderer))(next).ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusGatewayTimeout, rec.Code)
+}
+
+func TestTimeout_PropagatesPanic(t *testing.T) {
+	t.Parallel()
+
+	next := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("test panic")
+	})
+
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+
+	assert.PanicsWithValue(t, "test panic", func() {
+		middleware.Timeout(time.Second)(next).ServeHTTP(rec, req)
+	})
+}
+
+func TestTimeout_ResponseController(t *testing.T) {
+	t.Parallel()
+
+	base := newBaseWriter()
+	rw := deadlineWriter{baseWriter: base, calls: new(int)}
+
+	var beforeErr, afterErr error
+	done := make(chan struct{})
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		defer close(done)
+
+		rc := http.NewResponseController(rw)
+		beforeErr = rc.SetWriteDeadline(time.Now().Add(time.Second))
+
+		<-req.Context().Done()
+		time.Sleep(10 * time.Millisecond)
+
+		afterErr = rc.SetWriteDeadline(time.Now().Add(time.Second))
+	})
+
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil)
+
+	middleware.Timeout(10*time.Millisecond)(next).ServeHTTP(rw, req)
+	<-done
+
+	require.NoError(t, beforeErr)
+	require.ErrorIs(t, afterErr, http.ErrNotSupported)
+	assert.Equal(t, 1, *rw.calls)
+}
+
+type deadlineWriter struct {
+	*baseWriter
+
+	calls *int
+}
+
+func (w deadlineWriter) SetWriteDeadline(time.Time) error {
+	*w.calls++
+	return nil
+}
@@ -2,11 +2,21 @@
 // http request.
 package request
 
-import "context"
+import (
+	"context"
+	"net/netip"
+
+	"github.com/hamba/logger/v2"
+)
 
 type contextKey int
 
-const requestID contextKey = iota + 1
+const (
+	requestID contextKey = iota + 1
+	clientIP
+	requestLogger
+	cspNonce
+)
 
 // WithID returns a copy of parent in which the request ID value is set.
 func WithID(parent context.Context, id string) context.Context {
@@ -18,3 +28,36 @@ func IDFrom(ctx context.Context) (string, bool) {
 	id, ok := ctx.Value(requestID).(string)
 	return id, ok
 }
+
+// WithClientIP returns a copy of parent in which the client IP value is set.
+func WithClientIP(parent context.Context, ip netip.Addr) context.Context {
+	return context.WithValue(parent, clientIP, ip)
+}
+
+// ClientIPFrom returns the value of the client IP on the ctx.
+func ClientIPFrom(ctx context.Context) (netip.Addr, bool) {
+	ip, ok := ctx.Value(clientIP).(netip.Addr)
+	return ip, ok
+}
+
+// WithLogger returns a copy of parent in which the request logger value is set.
+func WithLogger(parent context.Context, log *logger.Logger) context.Context {
+	return context.WithValue(parent, requestLogger, log)
+}
+
+// LoggerFrom returns the value of the request logger on the ctx.
+func LoggerFrom(ctx context.Context) (*logger.Logger, bool) {
+	log, ok := ctx.Value(requestLogger).(*logger.Logger)
+	return log, ok
+}
+
+// WithCSPNonce returns a copy of parent in which the CSP nonce value is set.
+func WithCSPNonce(parent context.Context, nonce string) context.Context {
+	return context.WithValue(parent, cspNonce, nonce)
+}
+
+// CSPNonceFrom returns the value of the CSP nonce on the ctx.
+func CSPNonceFrom(ctx context.Context) (string, bool) {
+	nonce, ok := ctx.Value(cspNonce).(string)
+	return nonce, ok
+}
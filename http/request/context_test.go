@@ -2,8 +2,11 @@ package request_test
 
 import (
 	"context"
+	"io"
+	"net/netip"
 	"testing"
 
+	"github.com/hamba/logger/v2"
 	"github.com/hamba/pkg/v2/http/request"
 	"github.com/stretchr/testify/assert"
 )
@@ -16,3 +19,31 @@ func TestID(t *testing.T) {
 	assert.True(t, ok)
 	assert.Equal(t, "my-id", got)
 }
+
+func TestClientIP(t *testing.T) {
+	ctx := request.WithClientIP(context.Background(), netip.MustParseAddr("1.2.3.4"))
+
+	got, ok := request.ClientIPFrom(ctx)
+
+	assert.True(t, ok)
+	assert.Equal(t, netip.MustParseAddr("1.2.3.4"), got)
+}
+
+func TestLogger(t *testing.T) {
+	log := logger.New(io.Discard, logger.LogfmtFormat(), logger.Info)
+	ctx := request.WithLogger(context.Background(), log)
+
+	got, ok := request.LoggerFrom(ctx)
+
+	assert.True(t, ok)
+	assert.Same(t, log, got)
+}
+
+func TestCSPNonce(t *testing.T) {
+	ctx := request.WithCSPNonce(context.Background(), "my-nonce")
+
+	got, ok := request.CSPNonceFrom(ctx)
+
+	assert.True(t, ok)
+	assert.Equal(t, "my-nonce", got)
+}
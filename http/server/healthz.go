@@ -101,10 +101,12 @@ func (s *GenericServer[T]) installCheckers(mux *http.ServeMux, path string, chec
 	)
 
 	name := strings.TrimPrefix(path, "/")
-	h := healthz.Handler(name, func(output string) {
+	h := healthz.NewHandler(name, func(output string) {
 		s.Log.Info(fmt.Sprintf("%s check failed\n%s", name, output))
-	}, checks...)
-	mux.Handle(path, middleware.WithStats(name, s.Stats, h))
+	}, checks, healthz.Stats(s.Stats))
+	h = middleware.WithStats(name, s.Stats, h)
+	mux.Handle(path, h)
+	mux.Handle(path+"/{check}", h)
 }
 
 func checkNames(checks []healthz.HealthChecker) []string {
@@ -115,6 +117,8 @@ func checkNames(checks []healthz.HealthChecker) []string {
 	return names
 }
 
+var testHookShutdownCheck func()
+
 type shutdownCheck struct {
 	ch <-chan struct{}
 }
@@ -122,6 +126,10 @@ type shutdownCheck struct {
 func (s shutdownCheck) Name() string { return "shutdown" }
 
 func (s shutdownCheck) Check(*http.Request) error {
+	if testHookShutdownCheck != nil {
+		testHookShutdownCheck()
+	}
+
 	select {
 	case <-s.ch:
 		return errors.New("server is shutting down")
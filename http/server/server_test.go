@@ -7,6 +7,8 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"net/http/httptest"
+	"sync"
 	"testing"
 	"time"
 
@@ -260,16 +262,19 @@ func TestGenericServer_RunShutdownCausesReadyzToFail(t *testing.T) {
 	})
 	t.Cleanup(func() { setTestHookServerServe(nil) })
 
+	// Hold the shutdown check of the in-flight request until shutdown has started.
+	checkCalledCh := make(chan struct{})
+	releaseCh := make(chan struct{})
+	var once sync.Once
+	setTestHookShutdownCheck(func() {
+		once.Do(func() { close(checkCalledCh) })
+		<-releaseCh
+	})
+	t.Cleanup(func() { setTestHookShutdownCheck(nil) })
+
 	h := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
 
-	ctx, cancel := context.WithCancel(context.Background())
-	t.Cleanup(cancel)
-
-	check := healthz.NamedCheck("test", func(*http.Request) error {
-		cancel()
-		time.Sleep(time.Millisecond)
-		return nil
-	})
+	check := healthz.NamedCheck("test", func(*http.Request) error { return nil })
 
 	srv := &GenericServer[context.Context]{
 		Addr:    "localhost:0",
@@ -281,6 +286,9 @@ func TestGenericServer_RunShutdownCausesReadyzToFail(t *testing.T) {
 	err := srv.AddReadyzChecks(check)
 	require.NoError(t, err)
 
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
 	shutdownCh := make(chan struct{})
 	go func() {
 		defer close(shutdownCh)
@@ -296,11 +304,47 @@ func TestGenericServer_RunShutdownCausesReadyzToFail(t *testing.T) {
 		require.Fail(t, "Timed out waiting for server listener")
 	case ln = <-lnCh:
 	}
+	addr := ln.Addr().String()
 
-	url := "http://" + ln.Addr().String() + "/readyz?verbose=1"
-	statusCode, body := requireDoRequest(t, url)
-	assert.Equal(t, http.StatusInternalServerError, statusCode)
-	assert.Equal(t, "+ test ok\n- shutdown failed\nreadyz check failed\n", body)
+	type result struct {
+		statusCode int
+		body       string
+	}
+	resCh := make(chan result, 1)
+	go func() {
+		statusCode, body := requireDoRequest(t, "http://"+addr+"/readyz?verbose=1")
+		resCh <- result{statusCode: statusCode, body: body}
+	}()
+
+	select {
+	case <-time.After(30 * time.Second):
+		require.Fail(t, "Timed out waiting for readyz check")
+	case <-checkCalledCh:
+	}
+
+	cancel()
+
+	// The listener is only closed once the readyz shutdown check fails.
+	var d net.Dialer
+	require.Eventually(t, func() bool {
+		conn, err := d.DialContext(t.Context(), "tcp", addr)
+		if err != nil {
+			return true
+		}
+		_ = conn.Close()
+		return false
+	}, 30*time.Second, time.Millisecond)
+
+	close(releaseCh)
+
+	var res result
+	select {
+	case <-time.After(30 * time.Second):
+		require.Fail(t, "Timed out waiting for readyz response")
+	case res = <-resCh:
+	}
+	assert.Equal(t, http.StatusInternalServerError, res.statusCode)
+	assert.Equal(t, "+ test ok\n- shutdown failed\nreadyz check failed\n", res.body)
 
 	select {
 	case <-time.After(30 * time.Second):
@@ -309,6 +353,77 @@ func TestGenericServer_RunShutdownCausesReadyzToFail(t *testing.T) {
 	}
 }
 
+func TestGenericServer_ShutdownCausesReadyzToFail(t *testing.T) {
+	stats := statter.New(statter.DiscardReporter, 10*time.Second)
+	log := logger.New(io.Discard, logger.LogfmtFormat(), logger.Error)
+
+	check := healthz.NamedCheck("test", func(*http.Request) error { return nil })
+
+	srv := &GenericServer[context.Context]{
+		Stats: stats,
+		Log:   log,
+	}
+
+	err := srv.AddReadyzChecks(check)
+	require.NoError(t, err)
+
+	shutdownCh := make(chan struct{})
+	h := srv.installChecks(http.NotFoundHandler(), shutdownCh)
+
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/readyz?verbose=1", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	close(shutdownCh)
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, "+ test ok\n- shutdown failed\nreadyz check failed\n", rec.Body.String())
+}
+
+func TestGenericServer_HealthzSelectsChecks(t *testing.T) {
+	stats := statter.New(statter.DiscardReporter, 10*time.Second)
+	log := logger.New(io.Discard, logger.LogfmtFormat(), logger.Error)
+
+	check := healthz.NamedCheck("test", func(*http.Request) error { return nil })
+
+	srv := &GenericServer[context.Context]{
+		Stats: stats,
+		Log:   log,
+	}
+
+	err := srv.AddReadyzChecks(check)
+	require.NoError(t, err)
+
+	shutdownCh := make(chan struct{})
+	close(shutdownCh)
+	h := srv.installChecks(http.NotFoundHandler(), shutdownCh)
+
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/readyz/test?verbose=1", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "+ test ok\nreadyz check passed", rec.Body.String())
+
+	req = httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/readyz?verbose=1&exclude=shutdown", nil)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "+ test ok\nreadyz check passed", rec.Body.String())
+
+	req = httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/readyz?list", nil)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	assert.Equal(t, "test\nshutdown\n", rec.Body.String())
+}
+
 func TestGenericServer_RunHandlesServerError(t *testing.T) {
 	stats := statter.New(statter.DiscardReporter, 10*time.Second)
 	log := logger.New(io.Discard, logger.LogfmtFormat(), logger.Error)
@@ -427,6 +542,10 @@ func setTestHookServerServe(fn func(net.Listener)) {
 	testHookServerServe = fn
 }
 
+func setTestHookShutdownCheck(fn func()) {
+	testHookShutdownCheck = fn
+}
+
 var (
 	localhostCert = []byte(`-----BEGIN CERTIFICATE-----
 MIIDOTCCAiGgAwIBAgIQSRJrEpBGFc7tNb1fb5pKFzANBgkqhkiG9w0BAQsFADAS